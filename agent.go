@@ -0,0 +1,314 @@
+package synpse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgentVersionCount is the number of devices running an agent version.
+type AgentVersionCount struct {
+	Version string
+	Devices int
+}
+
+// unknownAgentVersion is reported for devices that haven't reported their agent version yet.
+const unknownAgentVersion = "unknown"
+
+// AgentVersionDistribution returns the number of devices per agent version for devices matching the
+// labels, most common versions first.
+func (api *API) AgentVersionDistribution(ctx context.Context, labels map[string]string) ([]AgentVersionCount, error) {
+	devices, err := api.listAllDevices(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	return agentVersionDistribution(devices), nil
+}
+
+func agentVersionDistribution(devices []*Device) []AgentVersionCount {
+	counts := make(map[string]int)
+	for _, device := range devices {
+		version := device.Info.AgentVersion
+		if version == "" {
+			version = unknownAgentVersion
+		}
+		counts[version]++
+	}
+
+	result := make([]AgentVersionCount, 0, len(counts))
+	for version, n := range counts {
+		result = append(result, AgentVersionCount{Version: version, Devices: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Devices != result[j].Devices {
+			return result[i].Devices > result[j].Devices
+		}
+		return compareAgentVersions(result[i].Version, result[j].Version) > 0
+	})
+	return result
+}
+
+// UpgradeAgentsOptions configures UpgradeAgents.
+type UpgradeAgentsOptions struct {
+	// Version is the desired agent version, required.
+	Version string
+	// Labels selects devices to upgrade, all devices are upgraded if empty.
+	Labels map[string]string
+	// BatchSize is the number of devices upgraded at once. Defaults to 10.
+	BatchSize int
+	// Force allows downgrades. Devices running a newer version are skipped otherwise.
+	Force bool
+	// BatchTimeout limits the wait for a batch to report the new version. Defaults to 10 minutes.
+	BatchTimeout time.Duration
+	// PollInterval is how often device versions are checked. Defaults to 10 seconds.
+	PollInterval time.Duration
+	// OnProgress is called after each batch status check, optional.
+	OnProgress func(AgentUpgradeStatus)
+}
+
+func (o *UpgradeAgentsOptions) setDefaults() {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 10
+	}
+	if o.BatchTimeout == 0 {
+		o.BatchTimeout = 10 * time.Minute
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = 10 * time.Second
+	}
+}
+
+// AgentUpgradeStatus is the progress of an agent upgrade.
+type AgentUpgradeStatus struct {
+	Batch    int // Current batch, starting from 1
+	Batches  int // Total number of batches
+	Upgraded int // Devices reporting the desired version
+	Pending  int // Devices in the current batch not reporting the desired version yet
+}
+
+// AgentUpgradeResult lists devices by the outcome of an agent upgrade.
+type AgentUpgradeResult struct {
+	Upgraded []*Device // Devices that report the desired version
+	Current  []*Device // Devices that already ran the desired version
+	Skipped  []*Device // Devices running a newer version, only when Force is not set
+	Deferred []*Device // Offline devices, they upgrade once they come back online
+	Pending  []*Device // Devices that did not report the desired version in time
+}
+
+// UpgradeAgents sets the desired agent version on devices matching the labels in batches and waits for each
+// batch to report the new version before moving to the next one. Offline devices get the desired version
+// set but are not waited for. When a batch times out, the upgrade stops and the result lists pending devices.
+func (api *API) UpgradeAgents(ctx context.Context, opts UpgradeAgentsOptions) (*AgentUpgradeResult, error) {
+	if opts.Version == "" {
+		return nil, fmt.Errorf("agent version not specified")
+	}
+	opts.setDefaults()
+
+	devices, err := api.listAllDevices(ctx, opts.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AgentUpgradeResult{}
+	var online []*Device
+	for _, device := range devices {
+		switch {
+		case compareAgentVersions(device.Info.AgentVersion, opts.Version) == 0:
+			result.Current = append(result.Current, device)
+		case !opts.Force && compareAgentVersions(device.Info.AgentVersion, opts.Version) > 0:
+			result.Skipped = append(result.Skipped, device)
+		case device.Status != DeviceStatusOnline:
+			err = api.setDesiredAgentVersion(ctx, device, opts.Version, opts.Force)
+			if err != nil {
+				return result, err
+			}
+			result.Deferred = append(result.Deferred, device)
+		default:
+			online = append(online, device)
+		}
+	}
+
+	batches := (len(online) + opts.BatchSize - 1) / opts.BatchSize
+	for i := 0; i < batches; i++ {
+		end := (i + 1) * opts.BatchSize
+		if end > len(online) {
+			end = len(online)
+		}
+		batch := online[i*opts.BatchSize : end]
+
+		for _, device := range batch {
+			err = api.setDesiredAgentVersion(ctx, device, opts.Version, opts.Force)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		upgraded, pending, err := api.waitForAgentVersion(ctx, batch, opts, func(upgraded, pending int) {
+			if opts.OnProgress != nil {
+				opts.OnProgress(AgentUpgradeStatus{
+					Batch:    i + 1,
+					Batches:  batches,
+					Upgraded: len(result.Upgraded) + upgraded,
+					Pending:  pending,
+				})
+			}
+		})
+		result.Upgraded = append(result.Upgraded, upgraded...)
+		if err != nil {
+			result.Pending = append(result.Pending, pending...)
+			result.Pending = append(result.Pending, online[end:]...)
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// setDesiredAgentVersion patches only the device agent settings, so that labels and environment variables
+// changed while the upgrade runs are not overwritten.
+func (api *API) setDesiredAgentVersion(ctx context.Context, device *Device, version string, force bool) error {
+	updated, err := api.updateDeviceConditional(ctx, device.ID, func(current *Device) (map[string]interface{}, bool) {
+		settings := current.AgentSettings
+		settings.DesiredAgentVersion = version
+		settings.DesiredAgentVersionForce = force
+		return map[string]interface{}{"agentSettings": settings}, settings != current.AgentSettings
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set desired agent version on device '%s': %w", device.Name, err)
+	}
+	*device = *updated
+	return nil
+}
+
+// waitForAgentVersion waits until all devices report the desired version, returning devices that did and
+// devices that didn't.
+func (api *API) waitForAgentVersion(ctx context.Context, devices []*Device, opts UpgradeAgentsOptions, progress func(upgraded, pending int)) ([]*Device, []*Device, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, opts.BatchTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	pending := devices
+	var upgraded []*Device
+	for {
+		var stillPending []*Device
+		for _, device := range pending {
+			current, err := api.GetDevice(waitCtx, device.ID)
+			if err == nil && compareAgentVersions(current.Info.AgentVersion, opts.Version) == 0 {
+				upgraded = append(upgraded, current)
+				continue
+			}
+			stillPending = append(stillPending, device)
+		}
+		pending = stillPending
+		progress(len(upgraded), len(pending))
+
+		if len(pending) == 0 {
+			return upgraded, nil, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return upgraded, pending, ctx.Err()
+			}
+			return upgraded, pending, fmt.Errorf("%d device(s) did not report agent version '%s': %w", len(pending), opts.Version, waitCtx.Err())
+		}
+	}
+}
+
+// compareAgentVersions compares agent versions such as "v1.2.3" following semver precedence, returning
+// -1, 0 or 1. Core version parts are compared numerically and a pre-release ("1.0.0-rc1") is lower than
+// its release. Build metadata is ignored and unknown versions sort before known ones.
+func compareAgentVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" || a == unknownAgentVersion {
+		return -1
+	}
+	if b == "" || b == unknownAgentVersion {
+		return 1
+	}
+
+	aCore, aPre := splitAgentVersion(a)
+	bCore, bPre := splitAgentVersion(b)
+
+	as := strings.Split(aCore, ".")
+	bs := strings.Split(bCore, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		ap, bp := "0", "0"
+		if i < len(as) {
+			ap = as[i]
+		}
+		if i < len(bs) {
+			bp = bs[i]
+		}
+		if c := compareVersionIdentifiers(ap, bp); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	as = strings.Split(aPre, ".")
+	bs = strings.Split(bPre, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareVersionIdentifiers(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// splitAgentVersion returns the core version and the pre-release of a version without build metadata.
+func splitAgentVersion(version string) (core, pre string) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.Index(version, "-"); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+// compareVersionIdentifiers compares numeric identifiers numerically and others as strings. Numeric
+// identifiers are lower than non-numeric ones.
+func compareVersionIdentifiers(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
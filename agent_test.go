@@ -0,0 +1,105 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareAgentVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.1", "1.2", 1},
+		{"", "0.0.1", -1},
+		{"unknown", "1.0.0", -1},
+		{"1.0.0", "", 1},
+		{"1.0.0-rc1", "1.0.0-rc2", -1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc1", 1},
+		{"v1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-rc1", "0.9.9", 1},
+		{"1.0.0+build.1", "1.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, compareAgentVersions(tt.a, tt.b))
+		})
+	}
+}
+
+func TestAgentVersionDistribution(t *testing.T) {
+	devices := []*Device{
+		{Info: DeviceInfo{AgentVersion: "1.9.0"}},
+		{Info: DeviceInfo{AgentVersion: "1.10.0"}},
+		{Info: DeviceInfo{AgentVersion: "1.10.0"}},
+		{Info: DeviceInfo{AgentVersion: "1.8.0"}},
+		{},
+	}
+
+	assert.Equal(t, []AgentVersionCount{
+		{Version: "1.10.0", Devices: 2},
+		{Version: "1.9.0", Devices: 1},
+		{Version: "1.8.0", Devices: 1},
+		{Version: "unknown", Devices: 1},
+	}, agentVersionDistribution(devices))
+}
+
+func TestUpgradeAgentsVersionPrefix(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		patches int
+		devices = map[string]*Device{
+			"current":  {ID: "current", Status: DeviceStatusOnline, Info: DeviceInfo{AgentVersion: "1.2.3"}},
+			"outdated": {ID: "outdated", Status: DeviceStatusOnline, Info: DeviceInfo{AgentVersion: "1.2.2"}},
+		}
+	)
+
+	client := getFakeServerClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := path.Base(r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && id == devicesURL:
+			_ = json.NewEncoder(w).Encode([]*Device{devices["current"], devices["outdated"]})
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(devices[id])
+		case r.Method == http.MethodPatch:
+			patches++
+			// The agent reports the version without the "v" prefix
+			devices[id].Info.AgentVersion = "1.2.3"
+			_ = json.NewEncoder(w).Encode(devices[id])
+		}
+	}))
+
+	result, err := client.UpgradeAgents(context.Background(), UpgradeAgentsOptions{
+		Version:      "v1.2.3",
+		BatchTimeout: time.Second,
+		PollInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, result.Current, 1)
+	assert.Equal(t, "current", result.Current[0].ID)
+	require.Len(t, result.Upgraded, 1)
+	assert.Equal(t, "outdated", result.Upgraded[0].ID)
+	assert.Empty(t, result.Pending)
+	assert.Equal(t, 1, patches, "only the outdated device should be upgraded")
+}
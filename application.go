@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/function61/holepunch-server/pkg/wsconnadapter"
@@ -45,6 +48,10 @@ func (api *API) CreateApplication(ctx context.Context, namespace string, applica
 		return nil, fmt.Errorf("namespace not selected")
 	}
 
+	if err := application.Validate(); err != nil {
+		return nil, err
+	}
+
 	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, applicationsURL), application)
 	if err != nil {
 		return nil, err
@@ -73,12 +80,23 @@ func (api *API) DeleteApplication(ctx context.Context, namespace, name string) e
 }
 
 // UpdateApplication updates application. This will trigger a version bump on the server side which will redeploy application on
-// all devices that the application is scheduled on.
+// all devices that the application is scheduled on. Only fields that are set are validated, as the update is applied as a patch.
+// If the server doesn't retain application history, the current application is saved to the revision store
+// before the update, see WithRevisionStore.
 func (api *API) UpdateApplication(ctx context.Context, namespace string, p Application) (*Application, error) {
 	if namespace == "" {
 		return nil, fmt.Errorf("namespace not selected")
 	}
 
+	if err := p.validatePatch(); err != nil {
+		return nil, err
+	}
+
+	err := api.snapshotApplication(ctx, namespace, p.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save application revision: %w", err)
+	}
+
 	resp, _, err := api.makeRequestContext(ctx, http.MethodPatch, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, applicationsURL, p.Name), p)
 	if err != nil {
 		return nil, err
@@ -148,6 +166,53 @@ func (api *API) DeviceApplicationLogs(ctx context.Context, namespace, applicatio
 	return wsconnadapter.New(wsConn), nil
 }
 
+// Validate checks the application scheduling and whether the application spec matches the application
+// runtime type. Applications without a type are treated as container applications.
+func (a *Application) Validate() error {
+	if err := a.Scheduling.Validate(); err != nil {
+		return fmt.Errorf("application '%s' scheduling: %w", a.Name, err)
+	}
+	return a.validateRuntime(a.Type)
+}
+
+// validatePatch validates only the fields set in a partial update. Scheduling is validated if set, and the
+// spec is checked against the runtime type only if the type is set, as the stored type is not known.
+func (a *Application) validatePatch() error {
+	if !reflect.DeepEqual(a.Scheduling, Scheduling{}) {
+		if err := a.Scheduling.Validate(); err != nil {
+			return fmt.Errorf("application '%s' scheduling: %w", a.Name, err)
+		}
+	}
+
+	if a.Type == "" {
+		if a.Spec.SystemdSpec != nil {
+			return a.Spec.SystemdSpec.Validate()
+		}
+		return nil
+	}
+	return a.validateRuntime(a.Type)
+}
+
+func (a *Application) validateRuntime(runtime RuntimeType) error {
+	switch runtime {
+	case RuntimeContainer, "":
+		if a.Spec.SystemdSpec != nil {
+			return fmt.Errorf("application '%s' is of type '%s' but has a systemd spec", a.Name, RuntimeContainer)
+		}
+	case RuntimeSystemd:
+		if len(a.Spec.ContainerSpec) > 0 {
+			return fmt.Errorf("application '%s' is of type '%s' but has container specs", a.Name, RuntimeSystemd)
+		}
+		if a.Spec.SystemdSpec == nil {
+			return fmt.Errorf("application '%s' is of type '%s' but has no systemd spec", a.Name, RuntimeSystemd)
+		}
+		return a.Spec.SystemdSpec.Validate()
+	default:
+		return fmt.Errorf("unknown application type '%s'", runtime)
+	}
+	return nil
+}
+
 // Application is
 type Application struct {
 	ID            string      `json:"id" yaml:"id"`
@@ -166,19 +231,6 @@ type Application struct {
 	DeploymentStatus ApplicationDeploymentStatus `json:"deploymentStatus,omitempty" yaml:"deploymentStatus,omitempty"` // computed
 }
 
-type Scheduling struct {
-	Type      ScheduleType      `json:"type"`
-	Selectors map[string]string `json:"selectors"`
-}
-
-type ScheduleType string
-
-const (
-	ScheduleTypeNoDevices   = "NoDevices" // Optional, defaults when no type and no selectors are specified
-	ScheduleTypeAllDevices  = "AllDevices"
-	ScheduleTypeConditional = "Conditional" // Optional, defaults when no type but selectors are specified
-)
-
 type RuntimeType string
 
 const (
@@ -189,6 +241,88 @@ const (
 type ApplicationSpec struct {
 	// Specs are parsed based on Type in main application struct
 	ContainerSpec []ContainerSpec `json:"containers,omitempty" yaml:"containers,omitempty" validate:"dive"`
+	SystemdSpec   *SystemdSpec    `json:"systemd,omitempty" yaml:"systemd,omitempty"`
+}
+
+// SystemdSpec describes a systemd unit that the agent installs and manages on the device. It is used
+// when the application Type is RuntimeSystemd.
+type SystemdSpec struct {
+	UnitName    string `json:"unitName" yaml:"unitName"` // E.g. "my-service.service", suffix is optional
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	ExecStart        string       `json:"execStart" yaml:"execStart"`
+	ExecStartPre     []string     `json:"execStartPre,omitempty" yaml:"execStartPre,omitempty"`
+	ExecStop         string       `json:"execStop,omitempty" yaml:"execStop,omitempty"`
+	WorkingDirectory string       `json:"workingDirectory,omitempty" yaml:"workingDirectory,omitempty"`
+	User             string       `json:"user,omitempty" yaml:"user,omitempty"`
+	Environment      Environments `json:"env,omitempty" yaml:"env,omitempty"`
+
+	Restart    SystemdRestartPolicy `json:"restart,omitempty" yaml:"restart,omitempty"`
+	RestartSec int                  `json:"restartSec,omitempty" yaml:"restartSec,omitempty"` // Seconds to wait before restarting
+
+	// Unit dependencies, e.g. "network-online.target"
+	After    []string `json:"after,omitempty" yaml:"after,omitempty"`
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+	Wants    []string `json:"wants,omitempty" yaml:"wants,omitempty"`
+	WantedBy []string `json:"wantedBy,omitempty" yaml:"wantedBy,omitempty"` // Defaults to multi-user.target on the agent
+
+	// Files are installed on the device before the unit is started
+	Files []SystemdFile `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// Validate checks required systemd spec fields.
+func (s *SystemdSpec) Validate() error {
+	if s.UnitName == "" {
+		return fmt.Errorf("systemd unit name not specified")
+	}
+	if strings.ContainsAny(s.UnitName, "/ ") {
+		return fmt.Errorf("invalid systemd unit name '%s'", s.UnitName)
+	}
+	if s.ExecStart == "" {
+		return fmt.Errorf("systemd unit '%s' has no execStart", s.UnitName)
+	}
+	if !s.Restart.valid() {
+		return fmt.Errorf("systemd unit '%s' has unknown restart policy '%s'", s.UnitName, s.Restart)
+	}
+	for _, f := range s.Files {
+		if !path.IsAbs(f.Path) {
+			return fmt.Errorf("systemd unit '%s' file path '%s' must be absolute", s.UnitName, f.Path)
+		}
+		if f.Content != "" && f.FromSecret != "" {
+			return fmt.Errorf("systemd unit '%s' file '%s' must set either content or fromSecret, not both", s.UnitName, f.Path)
+		}
+	}
+	return nil
+}
+
+// SystemdRestartPolicy maps to the systemd Restart= unit setting.
+type SystemdRestartPolicy string
+
+const (
+	SystemdRestartNo         SystemdRestartPolicy = "no"
+	SystemdRestartAlways     SystemdRestartPolicy = "always"
+	SystemdRestartOnSuccess  SystemdRestartPolicy = "on-success"
+	SystemdRestartOnFailure  SystemdRestartPolicy = "on-failure"
+	SystemdRestartOnAbnormal SystemdRestartPolicy = "on-abnormal"
+	SystemdRestartOnAbort    SystemdRestartPolicy = "on-abort"
+)
+
+func (p SystemdRestartPolicy) valid() bool {
+	switch p {
+	case "", SystemdRestartNo, SystemdRestartAlways, SystemdRestartOnSuccess,
+		SystemdRestartOnFailure, SystemdRestartOnAbnormal, SystemdRestartOnAbort:
+		return true
+	}
+	return false
+}
+
+// SystemdFile is a file installed on the device for the systemd unit, e.g. a config file or
+// the binary itself.
+type SystemdFile struct {
+	Path       string `json:"path" yaml:"path"`
+	Mode       string `json:"mode,omitempty" yaml:"mode,omitempty"` // Octal, e.g. "0644"
+	Content    string `json:"content,omitempty" yaml:"content,omitempty"`
+	FromSecret string `json:"fromSecret,omitempty" yaml:"fromSecret,omitempty"` // Populates content from a File type secret
 }
 
 type ContainerSpec struct {
@@ -216,20 +350,106 @@ type ContainerSpec struct {
 	VolumeDriver     string      `json:"volumeDriver,omitempty" yaml:"volumeDriver,omitempty" codec:"volume_driver"`
 	WorkDir          string      `json:"workDir,omitempty" yaml:"workDir,omitempty" codec:"work_dir"`
 
+	// Resource limits
+	CPUs              float64  `json:"cpus,omitempty" yaml:"cpus,omitempty" codec:"cpus"`                    // Number of CPUs, e.g. 1.5
+	CPUShares         int64    `json:"cpuShares,omitempty" yaml:"cpuShares,omitempty" codec:"cpu_shares"`    // Relative weight
+	CPUSetCPUs        string   `json:"cpusetCpus,omitempty" yaml:"cpusetCpus,omitempty" codec:"cpuset_cpus"` // CPUs in which to allow execution, e.g. 0-3 or 0,1
+	MemoryReservation int64    `json:"memoryReservation,omitempty" yaml:"memoryReservation,omitempty" codec:"memory_reservation"`
+	Ulimits           []Ulimit `json:"ulimits,omitempty" yaml:"ulimits,omitempty" codec:"ulimits"`
+
+	// Host integration
+	Devices []DeviceMapping   `json:"devices,omitempty" yaml:"devices,omitempty" codec:"devices"`
+	Sysctls map[string]string `json:"sysctls,omitempty" yaml:"sysctls,omitempty" codec:"sysctls"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty" codec:"labels"`
+
+	// Lifecycle
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty" yaml:"healthCheck,omitempty" codec:"health_check"`
+	LogConfig   *LogConfig   `json:"logConfig,omitempty" yaml:"logConfig,omitempty" codec:"log_config"`
+	StopSignal  string       `json:"stopSignal,omitempty" yaml:"stopSignal,omitempty" codec:"stop_signal"`    // E.g. SIGTERM
+	StopTimeout *int         `json:"stopTimeout,omitempty" yaml:"stopTimeout,omitempty" codec:"stop_timeout"` // Seconds to wait before killing the container
+	Init        bool         `json:"init,omitempty" yaml:"init,omitempty" codec:"init"`                       // Run an init inside the container that forwards signals and reaps processes
+
 	// synpse specific
 	Environment   Environments  `json:"env,omitempty" yaml:"env,omitempty" validate:"dive"`
 	Secrets       []SecretRef   `json:"secrets,omitempty" yaml:"secrets,omitempty"`
 	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty" yaml:"restartPolicy,omitempty"`
 }
 
+// HealthCheck configures a container health check, equivalent to the Dockerfile HEALTHCHECK instruction.
+type HealthCheck struct {
+	// Test is the command to run, e.g. ["CMD", "curl", "-f", "http://localhost"] or ["CMD-SHELL", "curl -f http://localhost"].
+	// ["NONE"] disables the health check inherited from the image.
+	Test        []string `json:"test,omitempty" yaml:"test,omitempty" codec:"test"`
+	Interval    string   `json:"interval,omitempty" yaml:"interval,omitempty" codec:"interval"`           // Duration, e.g. 30s
+	Timeout     string   `json:"timeout,omitempty" yaml:"timeout,omitempty" codec:"timeout"`              // Duration, e.g. 10s
+	StartPeriod string   `json:"startPeriod,omitempty" yaml:"startPeriod,omitempty" codec:"start_period"` // Duration, e.g. 1m
+	Retries     int      `json:"retries,omitempty" yaml:"retries,omitempty" codec:"retries"`
+}
+
+type Ulimit struct {
+	Name string `json:"name" yaml:"name" codec:"name"` // E.g. nofile
+	Soft int64  `json:"soft" yaml:"soft" codec:"soft"`
+	Hard int64  `json:"hard" yaml:"hard" codec:"hard"`
+}
+
+// DeviceMapping exposes a host device to the container, e.g. /dev/ttyUSB0.
+type DeviceMapping struct {
+	PathOnHost        string `json:"pathOnHost" yaml:"pathOnHost" codec:"path_on_host"`
+	PathInContainer   string `json:"pathInContainer,omitempty" yaml:"pathInContainer,omitempty" codec:"path_in_container"`      // Defaults to PathOnHost
+	CgroupPermissions string `json:"cgroupPermissions,omitempty" yaml:"cgroupPermissions,omitempty" codec:"cgroup_permissions"` // Defaults to rwm
+}
+
+type LogConfig struct {
+	Driver  string            `json:"driver,omitempty" yaml:"driver,omitempty" codec:"driver"` // E.g. json-file, journald
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty" codec:"options"`
+}
+
 type NetworkMode string
 
 const (
-	NetworkModeHost     = "host"
-	NetworkModeIsolated = "isolated"
-	NetworkModeBridge   = "bridge"
+	NetworkModeHost     NetworkMode = "host"
+	NetworkModeIsolated NetworkMode = "isolated"
+	NetworkModeBridge   NetworkMode = "bridge"
 )
 
+// NetworkModes returns all known container network modes.
+func NetworkModes() []NetworkMode {
+	return []NetworkMode{NetworkModeHost, NetworkModeIsolated, NetworkModeBridge}
+}
+
+// Valid returns true for known network modes and the empty mode, which leaves the choice to the agent.
+func (m NetworkMode) Valid() bool {
+	switch m {
+	case "", NetworkModeHost, NetworkModeIsolated, NetworkModeBridge:
+		return true
+	}
+	return false
+}
+
+func (m *NetworkMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return m.set(s)
+}
+
+func (m *NetworkMode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return m.set(s)
+}
+
+func (m *NetworkMode) set(s string) error {
+	if !NetworkMode(s).Valid() {
+		return fmt.Errorf("unknown network mode '%s', expected one of %v", s, NetworkModes())
+	}
+	*m = NetworkMode(s)
+	return nil
+}
+
 type Environment struct {
 	Name       string `json:"name" yaml:"name"`
 	Value      string `json:"value,omitempty" yaml:"value,omitempty"`
@@ -298,4 +518,6 @@ type ApplicationDeploymentStatus struct {
 	Pending   int `json:"pending" yaml:"pending"`
 	Available int `json:"available" yaml:"available"`
 	Total     int `json:"total" yaml:"total"`
+	Healthy   int `json:"healthy,omitempty" yaml:"healthy,omitempty"`     // Deployments where all containers with health checks are healthy
+	Unhealthy int `json:"unhealthy,omitempty" yaml:"unhealthy,omitempty"` // Deployments with at least one unhealthy container
 }
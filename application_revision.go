@@ -0,0 +1,321 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ApplicationRevision is a snapshot of the application at a specific version.
+type ApplicationRevision struct {
+	Version     int64       `json:"version" yaml:"version"`
+	CreatedAt   time.Time   `json:"createdAt" yaml:"createdAt"`
+	Application Application `json:"application" yaml:"application"`
+}
+
+// ListApplicationRevisions lists previous application versions, oldest first. If the server doesn't retain
+// application history, revisions are loaded from the client revision store (see WithRevisionStore).
+// ErrNotFound is returned if the application doesn't exist.
+func (api *API) ListApplicationRevisions(ctx context.Context, namespace, name string) ([]*ApplicationRevision, error) {
+	if namespace == "" {
+		return nil, ErrNamespaceNotSpecified
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, applicationsURL, name, revisionsURL), nil)
+	if err != nil {
+		if useStore, storeErr := api.useRevisionStore(ctx, namespace, name, err); useStore {
+			return api.revisionStore.ListApplicationRevisions(ctx, api.ProjectID, namespace, name)
+		} else if storeErr != nil {
+			return nil, storeErr
+		}
+		return nil, err
+	}
+
+	var result []*ApplicationRevision
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return result, nil
+}
+
+// GetApplicationRevision gets the application as it was at the specified version.
+func (api *API) GetApplicationRevision(ctx context.Context, namespace, name string, version int64) (*ApplicationRevision, error) {
+	if namespace == "" {
+		return nil, ErrNamespaceNotSpecified
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, applicationsURL, name, revisionsURL, strconv.FormatInt(version, 10)), nil)
+	if err != nil {
+		if useStore, storeErr := api.useRevisionStore(ctx, namespace, name, err); useStore {
+			return api.revisionStore.GetApplicationRevision(ctx, api.ProjectID, namespace, name, version)
+		} else if storeErr != nil {
+			return nil, storeErr
+		}
+		return nil, err
+	}
+
+	var result ApplicationRevision
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// RollbackApplication restores the application spec, scheduling and type from the specified version. Rollback
+// is an update by itself, so the application version is bumped rather than reset to toVersion.
+func (api *API) RollbackApplication(ctx context.Context, namespace, name string, toVersion int64) (*Application, error) {
+	revision, err := api.GetApplicationRevision(ctx, namespace, name, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application revision %d: %w", toVersion, err)
+	}
+
+	current, err := api.GetApplication(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	current.Description = revision.Application.Description
+	current.Type = revision.Application.Type
+	current.Scheduling = revision.Application.Scheduling
+	current.Spec = revision.Application.Spec
+
+	return api.UpdateApplication(ctx, namespace, *current)
+}
+
+// useRevisionStore reports whether a revisions request that failed with err should be served from the
+// revision store. That is only the case when the revisions endpoint itself is missing: if the server keeps
+// history, the original error is returned, and if the application doesn't exist, the error from getting it
+// is returned instead.
+func (api *API) useRevisionStore(ctx context.Context, namespace, name string, err error) (bool, error) {
+	if api.revisionStore == nil || !errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	keeps, err := api.serverKeepsRevisions(ctx, namespace, name)
+	if err != nil {
+		return false, err
+	}
+	if keeps {
+		return false, nil
+	}
+
+	_, err = api.GetApplication(ctx, namespace, name)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// serverKeepsRevisions reports whether the server has the application revisions endpoint. It is checked
+// by listing the application revisions: a 404 for an existing application means the endpoint is missing.
+// The result is cached. If the application doesn't exist, its ErrNotFound error is returned.
+func (api *API) serverKeepsRevisions(ctx context.Context, namespace, name string) (bool, error) {
+	if keeps, ok := api.revisionSupport.get(); ok {
+		return keeps, nil
+	}
+
+	_, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, applicationsURL, name, revisionsURL), nil)
+	if err == nil {
+		api.revisionSupport.set(true)
+		return true, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	_, err = api.GetApplication(ctx, namespace, name)
+	if err != nil {
+		return false, err
+	}
+	api.revisionSupport.set(false)
+	return false, nil
+}
+
+// revisionSupport caches whether the server has the application revisions endpoint. It is shared with
+// project scoped clients, as they use the same server.
+type revisionSupport struct {
+	mu    sync.Mutex
+	known bool
+	keeps bool
+}
+
+func (s *revisionSupport) get() (keeps, ok bool) {
+	if s == nil {
+		return false, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keeps, s.known
+}
+
+func (s *revisionSupport) set(keeps bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.known, s.keeps = true, keeps
+}
+
+// snapshotApplication saves the current application to the revision store when the server doesn't keep
+// application history. Nothing is saved if no revision store is configured or the application doesn't
+// exist yet.
+func (api *API) snapshotApplication(ctx context.Context, namespace, name string) error {
+	if api.revisionStore == nil {
+		return nil
+	}
+
+	keeps, err := api.serverKeepsRevisions(ctx, namespace, name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if keeps {
+		return nil
+	}
+
+	current, err := api.GetApplication(ctx, namespace, name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return api.revisionStore.SaveApplicationRevision(ctx, api.ProjectID, namespace, &ApplicationRevision{
+		Version:     current.Version,
+		CreatedAt:   current.UpdatedAt,
+		Application: *current,
+	})
+}
+
+// RevisionStore keeps application revisions on the client side for servers that don't retain
+// application history.
+type RevisionStore interface {
+	SaveApplicationRevision(ctx context.Context, projectID, namespace string, revision *ApplicationRevision) error
+	ListApplicationRevisions(ctx context.Context, projectID, namespace, name string) ([]*ApplicationRevision, error)
+	GetApplicationRevision(ctx context.Context, projectID, namespace, name string, version int64) (*ApplicationRevision, error)
+}
+
+// DirRevisionStore stores application revisions as JSON files in a local directory, laid out
+// as <dir>/<project>/<namespace>/<application>/<version>.json.
+type DirRevisionStore struct {
+	dir string
+}
+
+// NewDirRevisionStore creates a revision store in the specified directory. If dir is empty,
+// "synpse/revisions" in the user cache directory is used.
+func NewDirRevisionStore(dir string) (*DirRevisionStore, error) {
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user cache directory: %w", err)
+		}
+		dir = filepath.Join(cacheDir, "synpse", "revisions")
+	}
+
+	return &DirRevisionStore{dir: dir}, nil
+}
+
+func (s *DirRevisionStore) SaveApplicationRevision(ctx context.Context, projectID, namespace string, revision *ApplicationRevision) error {
+	dir, err := s.applicationDir(projectID, namespace, revision.Application.Name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	bts, err := json.MarshalIndent(revision, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, strconv.FormatInt(revision.Version, 10)+".json"), bts, 0600)
+}
+
+func (s *DirRevisionStore) ListApplicationRevisions(ctx context.Context, projectID, namespace, name string) ([]*ApplicationRevision, error) {
+	dir, err := s.applicationDir(projectID, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []*ApplicationRevision
+	for _, entry := range entries {
+		version, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), ".json"), 10, 64)
+		if entry.IsDir() || err != nil {
+			continue
+		}
+
+		revision, err := s.GetApplicationRevision(ctx, projectID, namespace, name, version)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, revision)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+func (s *DirRevisionStore) GetApplicationRevision(ctx context.Context, projectID, namespace, name string, version int64) (*ApplicationRevision, error) {
+	dir, err := s.applicationDir(projectID, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	bts, err := ioutil.ReadFile(filepath.Join(dir, strconv.FormatInt(version, 10)+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(ErrNotFound, "application '%s' revision %d", name, version)
+		}
+		return nil, err
+	}
+
+	var revision ApplicationRevision
+	err = json.Unmarshal(bts, &revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode application '%s' revision %d: %w", name, version, err)
+	}
+
+	return &revision, nil
+}
+
+func (s *DirRevisionStore) applicationDir(projectID, namespace, name string) (string, error) {
+	for _, part := range []string{projectID, namespace, name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid revision path element '%s'", part)
+		}
+	}
+	return filepath.Join(s.dir, projectID, namespace, name), nil
+}
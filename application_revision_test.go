@@ -0,0 +1,153 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirRevisionStore(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewDirRevisionStore(t.TempDir())
+	require.NoError(t, err)
+
+	revisions, err := store.ListApplicationRevisions(ctx, "prj_1", "default", "hello")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	for _, version := range []int64{3, 1, 2} {
+		err := store.SaveApplicationRevision(ctx, "prj_1", "default", &ApplicationRevision{
+			Version: version,
+			Application: Application{
+				Name:    "hello",
+				Version: version,
+				Spec: ApplicationSpec{
+					ContainerSpec: []ContainerSpec{{Name: "hello", Image: "hello:" + strconv.FormatInt(version, 10)}},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	revisions, err = store.ListApplicationRevisions(ctx, "prj_1", "default", "hello")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	for i, revision := range revisions {
+		assert.Equal(t, int64(i+1), revision.Version)
+	}
+
+	revision, err := store.GetApplicationRevision(ctx, "prj_1", "default", "hello", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "hello:2", revision.Application.Spec.ContainerSpec[0].Image)
+
+	_, err = store.GetApplicationRevision(ctx, "prj_1", "default", "hello", 4)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = store.ListApplicationRevisions(ctx, "prj_1", "..", "hello")
+	assert.Error(t, err)
+}
+
+// revisionServer serves a single application, with or without the revisions endpoint.
+type revisionServer struct {
+	revisions bool
+	app       Application
+	patches   int
+}
+
+func (s *revisionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	// projects/<project>/namespaces/<namespace>/applications/<name>[/revisions[/<version>]]
+	if len(parts) < 6 || parts[5] != s.app.Name {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 6 && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(s.app)
+	case len(parts) == 6 && r.Method == http.MethodPatch:
+		s.patches++
+		s.app.Version++
+		_ = json.NewEncoder(w).Encode(s.app)
+	case len(parts) == 7 && s.revisions:
+		_ = json.NewEncoder(w).Encode([]*ApplicationRevision{{Version: 1, Application: s.app}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestApplicationRevisionFallback(t *testing.T) {
+	ctx := context.Background()
+
+	newClient := func(t *testing.T, server *revisionServer) (*API, *DirRevisionStore) {
+		store, err := NewDirRevisionStore(t.TempDir())
+		require.NoError(t, err)
+
+		client := getFakeServerClient(t, server)
+		client.revisionStore = store
+		return client, store
+	}
+
+	t.Run("ServerKeepsHistory", func(t *testing.T) {
+		server := &revisionServer{revisions: true, app: Application{Name: "hello", Version: 1}}
+		client, store := newClient(t, server)
+
+		_, err := client.UpdateApplication(ctx, "default", Application{Name: "hello", Description: "updated"})
+		require.NoError(t, err)
+
+		stored, err := store.ListApplicationRevisions(ctx, client.ProjectID, "default", "hello")
+		require.NoError(t, err)
+		assert.Empty(t, stored, "nothing should be saved when the server keeps history")
+
+		_, err = client.GetApplicationRevision(ctx, "default", "hello", 5)
+		assert.True(t, errors.Is(err, ErrNotFound), "missing version should not fall back to the store")
+	})
+
+	t.Run("ServerWithoutHistory", func(t *testing.T) {
+		server := &revisionServer{app: Application{Name: "hello", Version: 1}}
+		client, _ := newClient(t, server)
+
+		_, err := client.UpdateApplication(ctx, "default", Application{Name: "hello", Description: "updated"})
+		require.NoError(t, err)
+
+		revisions, err := client.ListApplicationRevisions(ctx, "default", "hello")
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		assert.Equal(t, int64(1), revisions[0].Version)
+
+		revision, err := client.GetApplicationRevision(ctx, "default", "hello", 1)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", revision.Application.Name)
+	})
+
+	t.Run("MissingApplication", func(t *testing.T) {
+		server := &revisionServer{app: Application{Name: "hello", Version: 1}}
+		client, store := newClient(t, server)
+
+		require.NoError(t, store.SaveApplicationRevision(ctx, client.ProjectID, "default", &ApplicationRevision{
+			Version:     1,
+			Application: Application{Name: "deleted", Version: 1},
+		}))
+
+		_, err := client.ListApplicationRevisions(ctx, "default", "deleted")
+		assert.True(t, errors.Is(err, ErrNotFound), "expected application not found, got: %v", err)
+	})
+}
+
+func TestRevisionStoreDefault(t *testing.T) {
+	client, err := NewWithProject("test-access-key", "test-project")
+	require.NoError(t, err)
+	assert.IsType(t, &DirRevisionStore{}, client.revisionStore)
+
+	client, err = NewWithProject("test-access-key", "test-project", WithRevisionStore(nil))
+	require.NoError(t, err)
+	assert.Nil(t, client.revisionStore)
+}
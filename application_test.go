@@ -2,10 +2,13 @@ package synpse
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 const testAppPrefix = "sdk-test-"
@@ -88,3 +91,169 @@ func TestCreateApplication(t *testing.T) {
 		require.Error(t, err, "expected to get an error")
 	})
 }
+
+func TestApplicationValidate(t *testing.T) {
+	systemdSpec := &SystemdSpec{
+		UnitName:  "hello.service",
+		ExecStart: "/usr/local/bin/hello",
+		Restart:   SystemdRestartOnFailure,
+	}
+
+	tests := []struct {
+		name    string
+		app     Application
+		wantErr bool
+	}{
+		{"ContainerWithoutType", Application{Spec: ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "hello"}}}}, false},
+		{"Systemd", Application{Type: RuntimeSystemd, Spec: ApplicationSpec{SystemdSpec: systemdSpec}}, false},
+		{"SystemdWithoutSpec", Application{Type: RuntimeSystemd}, true},
+		{"SystemdWithContainers", Application{Type: RuntimeSystemd, Spec: ApplicationSpec{SystemdSpec: systemdSpec, ContainerSpec: []ContainerSpec{{Name: "hello"}}}}, true},
+		{"ContainerWithSystemdSpec", Application{Type: RuntimeContainer, Spec: ApplicationSpec{SystemdSpec: systemdSpec}}, true},
+		{"SystemdMissingExecStart", Application{Type: RuntimeSystemd, Spec: ApplicationSpec{SystemdSpec: &SystemdSpec{UnitName: "hello"}}}, true},
+		{"SystemdRelativeFile", Application{Type: RuntimeSystemd, Spec: ApplicationSpec{SystemdSpec: &SystemdSpec{
+			UnitName: "hello", ExecStart: "/bin/hello", Files: []SystemdFile{{Path: "etc/hello.conf"}},
+		}}}, true},
+		{"UnknownType", Application{Type: "vm"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.app.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplicationValidatePatch(t *testing.T) {
+	systemdSpec := &SystemdSpec{UnitName: "hello.service", ExecStart: "/usr/local/bin/hello"}
+
+	tests := []struct {
+		name    string
+		app     Application
+		wantErr bool
+	}{
+		{"SystemdSpecWithoutType", Application{Spec: ApplicationSpec{SystemdSpec: systemdSpec}}, false},
+		{"InvalidSystemdSpecWithoutType", Application{Spec: ApplicationSpec{SystemdSpec: &SystemdSpec{UnitName: "hello"}}}, true},
+		{"DescriptionOnly", Application{Description: "updated"}, false},
+		{"ContainerWithSystemdSpec", Application{Type: RuntimeContainer, Spec: ApplicationSpec{SystemdSpec: systemdSpec}}, true},
+		{"UnknownType", Application{Type: "vm"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.app.validatePatch()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSystemdApplicationEncoding(t *testing.T) {
+	app := Application{
+		Name: "hello",
+		Type: RuntimeSystemd,
+		Spec: ApplicationSpec{
+			SystemdSpec: &SystemdSpec{
+				UnitName:    "hello.service",
+				ExecStart:   "/usr/local/bin/hello --port 8080",
+				Environment: Environments{{Name: "FOO", Value: "bar"}},
+				Restart:     SystemdRestartAlways,
+				RestartSec:  5,
+				After:       []string{"network-online.target"},
+				Files: []SystemdFile{
+					{Path: "/etc/hello/config.yaml", Mode: "0644", Content: "port: 8080"},
+				},
+			},
+		},
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		bts, err := json.Marshal(app)
+		require.NoError(t, err)
+
+		var decoded Application
+		require.NoError(t, json.Unmarshal(bts, &decoded))
+		assert.Equal(t, app.Spec, decoded.Spec)
+		assert.Equal(t, app.Type, decoded.Type)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		bts, err := yaml.Marshal(app)
+		require.NoError(t, err)
+
+		var decoded Application
+		require.NoError(t, yaml.Unmarshal(bts, &decoded))
+		assert.Equal(t, app.Spec, decoded.Spec)
+		assert.Equal(t, app.Type, decoded.Type)
+	})
+}
+
+func TestContainerSpecEncoding(t *testing.T) {
+	stopTimeout := 30
+	container := ContainerSpec{
+		Name:              "app",
+		Image:             "app:latest",
+		CPUs:              1.5,
+		CPUShares:         512,
+		CPUSetCPUs:        "0,1",
+		MemoryReservation: 64 * 1024 * 1024,
+		Ulimits:           []Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+		Devices:           []DeviceMapping{{PathOnHost: "/dev/ttyUSB0", CgroupPermissions: "rw"}},
+		Sysctls:           map[string]string{"net.core.somaxconn": "1024"},
+		Labels:            map[string]string{"team": "edge"},
+		HealthCheck: &HealthCheck{
+			Test:     []string{"CMD-SHELL", "curl -f http://localhost"},
+			Interval: "30s",
+			Retries:  3,
+		},
+		LogConfig:   &LogConfig{Driver: "json-file", Options: map[string]string{"max-size": "10m"}},
+		StopSignal:  "SIGINT",
+		StopTimeout: &stopTimeout,
+		Init:        true,
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		bts, err := json.Marshal(container)
+		require.NoError(t, err)
+
+		var decoded ContainerSpec
+		require.NoError(t, json.Unmarshal(bts, &decoded))
+		assert.Equal(t, container, decoded)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		bts, err := yaml.Marshal(container)
+		require.NoError(t, err)
+
+		var decoded ContainerSpec
+		require.NoError(t, yaml.Unmarshal(bts, &decoded))
+		assert.Equal(t, container, decoded)
+	})
+}
+
+func TestWorkloadStatusesHealthy(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses WorkloadStatuses
+		want     bool
+	}{
+		{"Empty", nil, true},
+		{"NoHealthChecks", WorkloadStatuses{{Name: "app"}, {Name: "sidecar", Health: HealthStateNone}}, true},
+		{"Healthy", WorkloadStatuses{{Name: "app", Health: HealthStateHealthy}}, true},
+		{"Starting", WorkloadStatuses{{Name: "app", Health: HealthStateHealthy}, {Name: "sidecar", Health: HealthStateStarting}}, false},
+		{"Unhealthy", WorkloadStatuses{{Name: "app", Health: HealthStateUnhealthy}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.statuses.Healthy())
+		})
+	}
+}
@@ -15,9 +15,10 @@ import (
 )
 
 type ListDevicesRequest struct {
-	SearchQuery       string            // Search query, e.g. "power-plant-one"
-	Labels            map[string]string // A map of labels to match
-	PaginationOptions PaginationOptions
+	SearchQuery         string            // Search query, e.g. "power-plant-one"
+	Labels              map[string]string // A map of labels to match
+	RegistrationTokenID string            // Only devices registered with the token
+	PaginationOptions   PaginationOptions
 }
 
 type ListDevicesResponse struct {
@@ -47,6 +48,10 @@ func (api *API) ListDevices(ctx context.Context, req *ListDevicesRequest) (*List
 		q.Add("labels", string(bts))
 	}
 
+	if req.RegistrationTokenID != "" {
+		q.Add("registrationTokenId", req.RegistrationTokenID)
+	}
+
 	// Setting pagination
 	setPagination(q, &req.PaginationOptions)
 
@@ -70,6 +75,30 @@ func (api *API) ListDevices(ctx context.Context, req *ListDevicesRequest) (*List
 	}, nil
 }
 
+// listAllDevices lists all devices matching the labels, following pagination.
+func (api *API) listAllDevices(ctx context.Context, labels map[string]string) ([]*Device, error) {
+	return api.ListAllDevices(ctx, &ListDevicesRequest{Labels: labels})
+}
+
+// ListAllDevices lists all devices matching the request, following pagination. The request pagination
+// options are ignored, devices are listed in pages of MaxPageSize.
+func (api *API) ListAllDevices(ctx context.Context, req *ListDevicesRequest) ([]*Device, error) {
+	var devices []*Device
+	req.PaginationOptions = PaginationOptions{PageSize: MaxPageSize}
+	for {
+		resp, err := api.ListDevices(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, resp.Devices...)
+
+		if resp.Pagination.NextPageToken == "" || len(resp.Devices) == 0 {
+			return devices, nil
+		}
+		req.PaginationOptions.PageToken = resp.Pagination.NextPageToken
+	}
+}
+
 func (api *API) GetDevice(ctx context.Context, device string) (*Device, error) {
 	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, devicesURL, device+"?full"), nil)
 	if err != nil {
@@ -175,7 +204,7 @@ const (
 type AgentSettings struct {
 	AgentLogLevel            string `json:"agentLogLevel" yaml:"agentLogLevel"`
 	DesiredAgentVersion      string `json:"desiredAgentVersion" yaml:"desiredAgentVersion"`
-	DesiredAgentVersionForce bool   `json:"-" yaml:"-"` // TODO: Placeholder only so we can wire it in if needed from controller side. If this set to true agent will ignore downgrade checks
+	DesiredAgentVersionForce bool   `json:"desiredAgentVersionForce" yaml:"desiredAgentVersionForce"` // If this set to true agent will ignore downgrade checks
 }
 
 type DeviceInfo struct {
@@ -185,6 +214,8 @@ type DeviceInfo struct {
 	IPAddress     string     `json:"ipAddress" yaml:"ipAddress"`
 	Architecture  string     `json:"architecture" yaml:"architecture"`
 	Hostname      string     `json:"hostname" yaml:"hostname"`
+	MACAddress    string     `json:"macAddress,omitempty" yaml:"macAddress,omitempty"`     // MAC address of the primary network interface
+	SerialNumber  string     `json:"serialNumber,omitempty" yaml:"serialNumber,omitempty"` // Hardware serial number, if the agent can read it
 	OSRelease     OSRelease  `json:"osRelease" yaml:"osRelease"`
 	Docker        DockerInfo `json:"docker" yaml:"docker"`
 
@@ -0,0 +1,217 @@
+package synpse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Severity is the severity of a device health problem.
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityOK:
+		return "ok"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// DeviceHealthCheck identifies the check that found a problem.
+type DeviceHealthCheck string
+
+const (
+	DeviceHealthCheckLastSeen     DeviceHealthCheck = "lastSeen"
+	DeviceHealthCheckDocker       DeviceHealthCheck = "docker"
+	DeviceHealthCheckPrivileged   DeviceHealthCheck = "privileged"
+	DeviceHealthCheckAgentVersion DeviceHealthCheck = "agentVersion"
+)
+
+// DeviceProblem is a single problem found by a health check.
+type DeviceProblem struct {
+	Check    DeviceHealthCheck
+	Severity Severity
+	Message  string
+}
+
+// DeviceHealth is the health of a device.
+type DeviceHealth struct {
+	DeviceID string
+	Name     string
+	Severity Severity // Highest severity of the problems
+	Problems []DeviceProblem
+}
+
+// Healthy returns true if no problems were found.
+func (h *DeviceHealth) Healthy() bool {
+	return len(h.Problems) == 0
+}
+
+func (h *DeviceHealth) add(check DeviceHealthCheck, severity Severity, format string, args ...interface{}) {
+	h.Problems = append(h.Problems, DeviceProblem{Check: check, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	if severity > h.Severity {
+		h.Severity = severity
+	}
+}
+
+// DeviceHealthOptions configures device health evaluation. The zero value uses the defaults.
+type DeviceHealthOptions struct {
+	// StaleAfter is how long a device can go unseen before it's reported as a warning. Defaults to 5 minutes.
+	StaleAfter time.Duration
+	// CriticalAfter is how long a device can go unseen before it's reported as critical. Defaults to 1 hour.
+	CriticalAfter time.Duration
+	// AgentVersion is the expected agent version. Defaults to the device desired agent version, no drift
+	// is reported if neither is set.
+	AgentVersion string
+	// Now is the evaluation time. Defaults to the current time.
+	Now time.Time
+}
+
+func (o *DeviceHealthOptions) setDefaults() {
+	if o.StaleAfter == 0 {
+		o.StaleAfter = 5 * time.Minute
+	}
+	if o.CriticalAfter == 0 {
+		o.CriticalAfter = time.Hour
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+}
+
+// Health evaluates device health from its status, last seen time, Docker info, agent version and the
+// applications in Device.Applications.
+func (d *Device) Health(opts DeviceHealthOptions) *DeviceHealth {
+	opts.setDefaults()
+
+	health := &DeviceHealth{DeviceID: d.ID, Name: d.Name}
+
+	if d.LastSeenAt.IsZero() {
+		health.add(DeviceHealthCheckLastSeen, SeverityCritical, "device was never seen")
+	} else {
+		age := opts.Now.Sub(d.LastSeenAt).Truncate(time.Second)
+		switch {
+		case age >= opts.CriticalAfter:
+			health.add(DeviceHealthCheckLastSeen, SeverityCritical, "device last seen %s ago", age)
+		case age >= opts.StaleAfter:
+			health.add(DeviceHealthCheckLastSeen, SeverityWarning, "device last seen %s ago", age)
+		case d.Status == DeviceStatusOffline:
+			health.add(DeviceHealthCheckLastSeen, SeverityWarning, "device is offline")
+		}
+	}
+
+	docker := d.Info.Docker
+	if docker.Health != "" && docker.Health != "healthy" {
+		if docker.HealthDescription != "" {
+			health.add(DeviceHealthCheckDocker, SeverityCritical, "docker is %s: %s", docker.Health, docker.HealthDescription)
+		} else {
+			health.add(DeviceHealthCheckDocker, SeverityCritical, "docker is %s", docker.Health)
+		}
+	}
+
+	if !docker.PrivilegedEnabled {
+		for _, app := range d.Applications {
+			if requiresPrivileged(app) {
+				health.add(DeviceHealthCheckPrivileged, SeverityCritical, "application '%s' requires privileged containers but they are disabled", app.Name)
+			}
+		}
+	}
+
+	desired := opts.AgentVersion
+	if desired == "" {
+		desired = d.AgentSettings.DesiredAgentVersion
+	}
+	if desired != "" && compareAgentVersions(d.Info.AgentVersion, desired) != 0 {
+		health.add(DeviceHealthCheckAgentVersion, SeverityWarning, "agent version '%s' differs from desired '%s'", d.Info.AgentVersion, desired)
+	}
+
+	return health
+}
+
+func requiresPrivileged(app *Application) bool {
+	for _, container := range app.Spec.ContainerSpec {
+		if container.Privileged {
+			return true
+		}
+	}
+	return false
+}
+
+// FleetHealthReport is the health of devices, most severe first.
+type FleetHealthReport struct {
+	Devices  []*DeviceHealth
+	Healthy  int
+	Warning  int
+	Critical int
+}
+
+// DeviceHealthReport evaluates health of devices matching the label selector. Applications from all
+// namespaces are matched against the devices to check privileged container requirements.
+func (api *API) DeviceHealthReport(ctx context.Context, selector map[string]string, opts DeviceHealthOptions) (*FleetHealthReport, error) {
+	devices, err := api.listAllDevices(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := api.ListNamespaces(ctx, &ListNamespacesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	var privileged []*Application
+	for _, ns := range namespaces {
+		applications, err := api.ListApplications(ctx, &ListApplicationsRequest{Namespace: ns.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list applications in namespace '%s': %w", ns.Name, err)
+		}
+		for _, app := range applications {
+			if requiresPrivileged(app) {
+				privileged = append(privileged, app)
+			}
+		}
+	}
+
+	return fleetHealthReport(devices, privileged, opts), nil
+}
+
+func fleetHealthReport(devices []*Device, privileged []*Application, opts DeviceHealthOptions) *FleetHealthReport {
+	opts.setDefaults()
+
+	report := &FleetHealthReport{}
+	for _, device := range devices {
+		d := *device
+		d.Applications = nil
+		for _, app := range privileged {
+			if app.Scheduling.Matches(&d) {
+				d.Applications = append(d.Applications, app)
+			}
+		}
+
+		health := d.Health(opts)
+		switch health.Severity {
+		case SeverityOK:
+			report.Healthy++
+		case SeverityWarning:
+			report.Warning++
+		case SeverityCritical:
+			report.Critical++
+		}
+		report.Devices = append(report.Devices, health)
+	}
+
+	sort.SliceStable(report.Devices, func(i, j int) bool {
+		return report.Devices[i].Severity > report.Devices[j].Severity
+	})
+	return report
+}
@@ -0,0 +1,129 @@
+package synpse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceHealth(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	privilegedApp := &Application{Name: "vpn", Spec: ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "vpn", Privileged: true}}}}
+
+	tests := []struct {
+		name     string
+		device   Device
+		opts     DeviceHealthOptions
+		severity Severity
+		problems []string
+	}{
+		{
+			name:     "healthy",
+			device:   Device{Status: DeviceStatusOnline, LastSeenAt: now.Add(-time.Minute), Info: DeviceInfo{Docker: DockerInfo{Health: "healthy"}}},
+			severity: SeverityOK,
+		},
+		{
+			name:     "never seen",
+			device:   Device{},
+			severity: SeverityCritical,
+			problems: []string{"device was never seen"},
+		},
+		{
+			name:     "stale",
+			device:   Device{Status: DeviceStatusOnline, LastSeenAt: now.Add(-10 * time.Minute)},
+			severity: SeverityWarning,
+			problems: []string{"device last seen 10m0s ago"},
+		},
+		{
+			name:     "custom thresholds",
+			device:   Device{Status: DeviceStatusOffline, LastSeenAt: now.Add(-10 * time.Minute)},
+			opts:     DeviceHealthOptions{StaleAfter: time.Minute, CriticalAfter: 5 * time.Minute},
+			severity: SeverityCritical,
+			problems: []string{"device last seen 10m0s ago"},
+		},
+		{
+			name:     "offline",
+			device:   Device{Status: DeviceStatusOffline, LastSeenAt: now.Add(-time.Minute)},
+			severity: SeverityWarning,
+			problems: []string{"device is offline"},
+		},
+		{
+			name: "docker unhealthy and privileged disabled",
+			device: Device{
+				Status:       DeviceStatusOnline,
+				LastSeenAt:   now,
+				Info:         DeviceInfo{Docker: DockerInfo{Health: "unhealthy", HealthDescription: "daemon not responding"}},
+				Applications: []*Application{privilegedApp},
+			},
+			severity: SeverityCritical,
+			problems: []string{
+				"docker is unhealthy: daemon not responding",
+				"application 'vpn' requires privileged containers but they are disabled",
+			},
+		},
+		{
+			name: "agent version drift",
+			device: Device{
+				Status:        DeviceStatusOnline,
+				LastSeenAt:    now,
+				AgentSettings: AgentSettings{DesiredAgentVersion: "1.3.0"},
+				Info:          DeviceInfo{AgentVersion: "1.2.0"},
+			},
+			severity: SeverityWarning,
+			problems: []string{"agent version '1.2.0' differs from desired '1.3.0'"},
+		},
+		{
+			name: "agent version without prefix",
+			device: Device{
+				Status:        DeviceStatusOnline,
+				LastSeenAt:    now,
+				AgentSettings: AgentSettings{DesiredAgentVersion: "v1.3.0"},
+				Info:          DeviceInfo{AgentVersion: "1.3.0"},
+			},
+			severity: SeverityOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Now = now
+			health := tt.device.Health(tt.opts)
+
+			var problems []string
+			for _, p := range health.Problems {
+				problems = append(problems, p.Message)
+			}
+			assert.Equal(t, tt.problems, problems)
+			assert.Equal(t, tt.severity, health.Severity)
+			assert.Equal(t, len(tt.problems) == 0, health.Healthy())
+		})
+	}
+}
+
+func TestFleetHealthReport(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	devices := []*Device{
+		{ID: "dev_1", Status: DeviceStatusOnline, LastSeenAt: now, Labels: map[string]string{"type": "gateway"}},
+		{ID: "dev_2", Status: DeviceStatusOnline, LastSeenAt: now, Labels: map[string]string{"type": "sensor"}},
+		{ID: "dev_3", Status: DeviceStatusOffline, LastSeenAt: now.Add(-time.Minute)},
+	}
+	privileged := []*Application{{
+		Name:       "vpn",
+		Scheduling: Scheduling{Type: ScheduleTypeConditional, Selectors: map[string]string{"type": "gateway"}},
+		Spec:       ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "vpn", Privileged: true}}},
+	}}
+
+	report := fleetHealthReport(devices, privileged, DeviceHealthOptions{Now: now})
+
+	require.Len(t, report.Devices, 3)
+	assert.Equal(t, 1, report.Healthy)
+	assert.Equal(t, 1, report.Warning)
+	assert.Equal(t, 1, report.Critical)
+	assert.Equal(t, "dev_1", report.Devices[0].DeviceID)
+	assert.Equal(t, DeviceHealthCheckPrivileged, report.Devices[0].Problems[0].Check)
+	assert.Equal(t, "dev_3", report.Devices[1].DeviceID)
+	assert.Equal(t, "dev_2", report.Devices[2].DeviceID)
+	assert.Nil(t, devices[0].Applications, "listed devices must not be modified")
+}
@@ -0,0 +1,217 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// maxDeviceUpdateAttempts is how many times a label or environment variable update is retried when the
+// device is modified concurrently.
+const maxDeviceUpdateAttempts = 5
+
+const (
+	deviceLabelsField = "labels"
+	deviceEnvField    = "environmentVariables"
+)
+
+// Label and environment variable updates only send the changed field and are conditional on the device
+// not having changed since it was read, retrying with a fresh read on ErrConflict. The check is done by the
+// server with If-Match (when it returns an ETag) or If-Unmodified-Since, which cannot detect changes made
+// within the same second. If the server ignores both headers the update is applied unconditionally, though
+// only the merged field is written.
+
+// SetDeviceLabels adds or overwrites the labels, keeping other device labels.
+func (api *API) SetDeviceLabels(ctx context.Context, deviceID string, labels map[string]string) (*Device, error) {
+	return api.mergeDeviceMap(ctx, deviceID, deviceLabelsField, labels, nil)
+}
+
+// RemoveDeviceLabels removes the labels with the keys, keeping other device labels.
+func (api *API) RemoveDeviceLabels(ctx context.Context, deviceID string, keys ...string) (*Device, error) {
+	return api.mergeDeviceMap(ctx, deviceID, deviceLabelsField, nil, keys)
+}
+
+// SetDeviceEnv adds or overwrites the environment variables, keeping other device environment variables.
+func (api *API) SetDeviceEnv(ctx context.Context, deviceID string, env map[string]string) (*Device, error) {
+	return api.mergeDeviceMap(ctx, deviceID, deviceEnvField, env, nil)
+}
+
+// RemoveDeviceEnv removes the environment variables, keeping other device environment variables.
+func (api *API) RemoveDeviceEnv(ctx context.Context, deviceID string, names ...string) (*Device, error) {
+	return api.mergeDeviceMap(ctx, deviceID, deviceEnvField, nil, names)
+}
+
+// BulkDeviceResult is the result of a bulk device update.
+type BulkDeviceResult struct {
+	Updated []*Device
+	Failed  map[string]error // Errors by device ID
+}
+
+func (r *BulkDeviceResult) err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to update %d of %d device(s)", len(r.Failed), len(r.Failed)+len(r.Updated))
+}
+
+// SetDevicesLabels sets the labels on all devices matching the label selector.
+func (api *API) SetDevicesLabels(ctx context.Context, selector, labels map[string]string) (*BulkDeviceResult, error) {
+	return api.bulkMergeDeviceMap(ctx, selector, deviceLabelsField, labels, nil)
+}
+
+// RemoveDevicesLabels removes the labels from all devices matching the label selector.
+func (api *API) RemoveDevicesLabels(ctx context.Context, selector map[string]string, keys ...string) (*BulkDeviceResult, error) {
+	return api.bulkMergeDeviceMap(ctx, selector, deviceLabelsField, nil, keys)
+}
+
+// SetDevicesEnv sets the environment variables on all devices matching the label selector.
+func (api *API) SetDevicesEnv(ctx context.Context, selector, env map[string]string) (*BulkDeviceResult, error) {
+	return api.bulkMergeDeviceMap(ctx, selector, deviceEnvField, env, nil)
+}
+
+// RemoveDevicesEnv removes the environment variables from all devices matching the label selector.
+func (api *API) RemoveDevicesEnv(ctx context.Context, selector map[string]string, names ...string) (*BulkDeviceResult, error) {
+	return api.bulkMergeDeviceMap(ctx, selector, deviceEnvField, nil, names)
+}
+
+// bulkMergeDeviceMap updates every matching device, continuing past failures. The returned error is
+// non-nil if any device failed to update, details are in BulkDeviceResult.Failed.
+func (api *API) bulkMergeDeviceMap(ctx context.Context, selector map[string]string, field string, set map[string]string, remove []string) (*BulkDeviceResult, error) {
+	devices, err := api.listAllDevices(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BulkDeviceResult{Failed: make(map[string]error)}
+	for _, device := range devices {
+		updated, err := api.mergeDeviceMap(ctx, device.ID, field, set, remove)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Failed[device.ID] = err
+			continue
+		}
+		result.Updated = append(result.Updated, updated)
+	}
+	return result, result.err()
+}
+
+// mergeDeviceMap reads the device, merges the labels or environment variables and writes back only that
+// field.
+func (api *API) mergeDeviceMap(ctx context.Context, deviceID, field string, set map[string]string, remove []string) (*Device, error) {
+	return api.updateDeviceConditional(ctx, deviceID, func(device *Device) (map[string]interface{}, bool) {
+		current := device.Labels
+		if field == deviceEnvField {
+			current = device.EnvironmentVariables
+		}
+		merged, changed := mergeStringMap(current, set, remove)
+		return map[string]interface{}{field: merged}, changed
+	})
+}
+
+// updateDeviceConditional reads the device and patches it with the fields returned by patch, which returns
+// false if no update is needed. The write is conditional on the device not having changed since it was
+// read; on conflict the device is read again and the patch retried.
+func (api *API) updateDeviceConditional(ctx context.Context, deviceID string, patch func(device *Device) (map[string]interface{}, bool)) (*Device, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("device ID not specified")
+	}
+
+	var err error
+	for attempt := 0; attempt < maxDeviceUpdateAttempts; attempt++ {
+		var (
+			device *Device
+			header http.Header
+		)
+		device, header, err = api.getDeviceWithHeader(ctx, deviceID)
+		if err != nil {
+			return nil, err
+		}
+
+		body, ok := patch(device)
+		if !ok {
+			return device, nil
+		}
+
+		var updated *Device
+		updated, err = api.patchDeviceConditional(ctx, device, header, body)
+		if err == nil {
+			return updated, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("device '%s' updated concurrently, gave up after %d attempts: %w", deviceID, maxDeviceUpdateAttempts, err)
+}
+
+func (api *API) getDeviceWithHeader(ctx context.Context, deviceID string) (*Device, http.Header, error) {
+	resp, header, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, devicesURL, deviceID), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var d Device
+	err = json.Unmarshal(resp, &d)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errUnmarshalError)
+	}
+	return &d, header, nil
+}
+
+// patchDeviceConditional patches the device if it has not changed since it was read. The ETag of the read
+// response is sent as If-Match when available, otherwise the device UpdatedAt time as If-Unmodified-Since,
+// which only has one second precision. The precondition is enforced by the server: a server that ignores
+// these headers applies the patch unconditionally.
+func (api *API) patchDeviceConditional(ctx context.Context, device *Device, readHeader http.Header, body interface{}) (*Device, error) {
+	headers := make(http.Header)
+	if etag := readHeader.Get("ETag"); etag != "" {
+		headers.Set("If-Match", etag)
+	} else if !device.UpdatedAt.IsZero() {
+		headers.Set("If-Unmodified-Since", device.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	resp, _, err := api.makeRequestWithAuthTypeAndHeaders(ctx, http.MethodPatch, getURL(api.BaseURL, projectsURL, api.ProjectID, devicesURL, device.ID), body, api.authType, headers)
+	if err != nil {
+		if len(headers) > 0 && errors.Is(err, ErrStatusConflict) {
+			// The request is conditional, a conflict means the device has changed since it was read
+			return nil, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return nil, err
+	}
+
+	var d Device
+	err = json.Unmarshal(resp, &d)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+	return &d, nil
+}
+
+// mergeStringMap returns a copy of current with set applied and remove keys deleted, and whether the
+// result differs from current.
+func mergeStringMap(current, set map[string]string, remove []string) (map[string]string, bool) {
+	merged := make(map[string]string, len(current)+len(set))
+	for k, v := range current {
+		merged[k] = v
+	}
+
+	changed := false
+	for k, v := range set {
+		if old, ok := merged[k]; !ok || old != v {
+			merged[k] = v
+			changed = true
+		}
+	}
+	for _, k := range remove {
+		if _, ok := merged[k]; ok {
+			delete(merged, k)
+			changed = true
+		}
+	}
+	return merged, changed
+}
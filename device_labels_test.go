@@ -0,0 +1,177 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeStringMap(t *testing.T) {
+	current := map[string]string{"site": "a", "type": "gateway"}
+
+	tests := []struct {
+		name    string
+		set     map[string]string
+		remove  []string
+		want    map[string]string
+		changed bool
+	}{
+		{
+			name:    "add",
+			set:     map[string]string{"gpu": "true"},
+			want:    map[string]string{"site": "a", "type": "gateway", "gpu": "true"},
+			changed: true,
+		},
+		{
+			name:    "overwrite",
+			set:     map[string]string{"site": "b"},
+			want:    map[string]string{"site": "b", "type": "gateway"},
+			changed: true,
+		},
+		{
+			name: "same value",
+			set:  map[string]string{"site": "a"},
+			want: map[string]string{"site": "a", "type": "gateway"},
+		},
+		{
+			name:    "remove",
+			remove:  []string{"type", "missing"},
+			want:    map[string]string{"site": "a"},
+			changed: true,
+		},
+		{
+			name:   "remove missing",
+			remove: []string{"missing"},
+			want:   map[string]string{"site": "a", "type": "gateway"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, changed := mergeStringMap(current, tt.set, tt.remove)
+			assert.Equal(t, tt.want, merged)
+			assert.Equal(t, tt.changed, changed)
+		})
+	}
+
+	assert.Equal(t, map[string]string{"site": "a", "type": "gateway"}, current, "current map must not be modified")
+
+	merged, changed := mergeStringMap(nil, nil, []string{"site"})
+	assert.Equal(t, map[string]string{}, merged)
+	assert.False(t, changed)
+}
+
+// conditionalDeviceServer serves a single device. The first updates, up to failures, are rejected with
+// status as if the device had changed since it was read.
+type conditionalDeviceServer struct {
+	etag     string
+	device   Device
+	failures int
+	status   int
+
+	gets      int
+	patches   int
+	ifMatch   []string
+	ifUnmod   []string
+	lastPatch map[string]interface{}
+}
+
+func (s *conditionalDeviceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.gets++
+		if s.etag != "" {
+			w.Header().Set("ETag", s.etag)
+		}
+		_ = json.NewEncoder(w).Encode(s.device)
+	case http.MethodPatch:
+		s.patches++
+		s.ifMatch = append(s.ifMatch, r.Header.Get("If-Match"))
+		s.ifUnmod = append(s.ifUnmod, r.Header.Get("If-Unmodified-Since"))
+		if s.patches <= s.failures {
+			w.WriteHeader(s.status)
+			return
+		}
+		s.lastPatch = nil
+		_ = json.NewDecoder(r.Body).Decode(&s.lastPatch)
+		if labels, ok := s.lastPatch[deviceLabelsField].(map[string]interface{}); ok {
+			s.device.Labels = make(map[string]string, len(labels))
+			for k, v := range labels {
+				s.device.Labels[k] = v.(string)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(s.device)
+	}
+}
+
+func TestSetDeviceLabelsConditional(t *testing.T) {
+	updatedAt := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	t.Run("IfMatch", func(t *testing.T) {
+		server := &conditionalDeviceServer{etag: `"v1"`, device: Device{ID: "dev", UpdatedAt: updatedAt, Labels: map[string]string{"site": "a"}}}
+		client := getFakeServerClient(t, server)
+
+		device, err := client.SetDeviceLabels(context.Background(), "dev", map[string]string{"gpu": "true"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"site": "a", "gpu": "true"}, device.Labels)
+		assert.Equal(t, []string{`"v1"`}, server.ifMatch)
+		assert.Equal(t, []string{""}, server.ifUnmod)
+		assert.Len(t, server.lastPatch, 1, "only the labels should be sent")
+	})
+
+	t.Run("IfUnmodifiedSince", func(t *testing.T) {
+		server := &conditionalDeviceServer{device: Device{ID: "dev", UpdatedAt: updatedAt}}
+		client := getFakeServerClient(t, server)
+
+		_, err := client.SetDeviceLabels(context.Background(), "dev", map[string]string{"gpu": "true"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{""}, server.ifMatch)
+		assert.Equal(t, []string{"Fri, 04 Mar 2022 05:06:07 GMT"}, server.ifUnmod)
+	})
+
+	t.Run("RetryOnPreconditionFailed", func(t *testing.T) {
+		server := &conditionalDeviceServer{etag: `"v1"`, device: Device{ID: "dev"}, failures: 2, status: http.StatusPreconditionFailed}
+		client := getFakeServerClient(t, server)
+
+		device, err := client.SetDeviceLabels(context.Background(), "dev", map[string]string{"gpu": "true"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"gpu": "true"}, device.Labels)
+		assert.Equal(t, 3, server.gets, "device should be read again before each retry")
+		assert.Equal(t, 3, server.patches)
+	})
+
+	t.Run("RetryOnConflict", func(t *testing.T) {
+		server := &conditionalDeviceServer{etag: `"v1"`, device: Device{ID: "dev"}, failures: 1, status: http.StatusConflict}
+		client := getFakeServerClient(t, server)
+
+		_, err := client.SetDeviceLabels(context.Background(), "dev", map[string]string{"gpu": "true"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, server.patches)
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		server := &conditionalDeviceServer{etag: `"v1"`, device: Device{ID: "dev"}, failures: maxDeviceUpdateAttempts + 1, status: http.StatusPreconditionFailed}
+		client := getFakeServerClient(t, server)
+
+		_, err := client.SetDeviceLabels(context.Background(), "dev", map[string]string{"gpu": "true"})
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrConflict))
+		assert.Equal(t, maxDeviceUpdateAttempts, server.gets)
+		assert.Equal(t, maxDeviceUpdateAttempts, server.patches)
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		server := &conditionalDeviceServer{device: Device{ID: "dev", Labels: map[string]string{"gpu": "true"}}}
+		client := getFakeServerClient(t, server)
+
+		_, err := client.SetDeviceLabels(context.Background(), "dev", map[string]string{"gpu": "true"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, server.patches)
+	})
+}
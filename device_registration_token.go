@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -29,6 +31,10 @@ func (api *API) ListDeviceRegistrationTokens(ctx context.Context, req *ListDevic
 }
 
 func (api *API) CreateRegistrationToken(ctx context.Context, registrationToken DeviceRegistrationToken) (*DeviceRegistrationToken, error) {
+	err := registrationToken.NamingStrategy.Validate()
+	if err != nil {
+		return nil, err
+	}
 	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, deviceRegistrationTokenURL), registrationToken)
 	if err != nil {
 		return nil, err
@@ -47,6 +53,10 @@ func (api *API) UpdateRegistrationToken(ctx context.Context, registrationToken D
 	if registrationToken.ID == "" {
 		return nil, fmt.Errorf("registration token ID not specified")
 	}
+	err := registrationToken.NamingStrategy.Validate()
+	if err != nil {
+		return nil, err
+	}
 	resp, _, err := api.makeRequestContext(ctx, http.MethodPut, getURL(api.BaseURL, projectsURL, api.ProjectID, deviceRegistrationTokenURL, registrationToken.ID), registrationToken)
 	if err != nil {
 		return nil, err
@@ -69,8 +79,53 @@ func (api *API) DeleteRegistrationToken(ctx context.Context, registrationToken s
 	return err
 }
 
+// GetDefaultDeviceRegistrationToken returns the default registration token of the project, the one the
+// server created together with the project. If project is empty, the current project is used.
 func (api *API) GetDefaultDeviceRegistrationToken(ctx context.Context, project string) (*DeviceRegistrationToken, error) {
-	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, deviceRegistrationTokenURL), nil)
+	if project == "" {
+		project = api.ProjectID
+	}
+
+	tokens, err := api.Project(project).ListDeviceRegistrationTokens(ctx, &ListDeviceRegistrationTokensRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	token := defaultRegistrationToken(tokens, project)
+	if token == nil {
+		return nil, fmt.Errorf("default registration token in project '%s': %w", project, ErrNotFound)
+	}
+	return token, nil
+}
+
+// defaultRegistrationToken returns the project token marked as default by the server. If none is marked,
+// the only token of the project is returned, as projects start with a single token.
+func defaultRegistrationToken(tokens []*DeviceRegistrationToken, project string) *DeviceRegistrationToken {
+	var projectTokens []*DeviceRegistrationToken
+	for _, token := range tokens {
+		if token.ProjectID != "" && token.ProjectID != project {
+			continue
+		}
+		if token.Default {
+			return token
+		}
+		projectTokens = append(projectTokens, token)
+	}
+
+	if len(projectTokens) == 1 {
+		return projectTokens[0]
+	}
+	return nil
+}
+
+// RevokeRegistrationToken revokes the token so that no more devices can register with it. The revocation
+// time is set by the server. Devices that are already registered are not affected.
+func (api *API) RevokeRegistrationToken(ctx context.Context, registrationToken string) (*DeviceRegistrationToken, error) {
+	if registrationToken == "" {
+		return nil, fmt.Errorf("registration token ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, deviceRegistrationTokenURL, registrationToken, revokeURL), []byte{})
 	if err != nil {
 		return nil, err
 	}
@@ -80,9 +135,32 @@ func (api *API) GetDefaultDeviceRegistrationToken(ctx context.Context, project s
 	if err != nil {
 		return nil, errors.Wrap(err, errUnmarshalError)
 	}
+
 	return &result, nil
 }
 
+// ListRegistrationTokenDevices returns devices that were registered with the token. Devices are filtered
+// by the server; the result is also checked on the client side, so with a server that ignores the filter
+// this lists every device in the project, which is slow for large fleets.
+func (api *API) ListRegistrationTokenDevices(ctx context.Context, registrationToken string) ([]*Device, error) {
+	if registrationToken == "" {
+		return nil, fmt.Errorf("registration token ID not specified")
+	}
+
+	devices, err := api.ListAllDevices(ctx, &ListDevicesRequest{RegistrationTokenID: registrationToken})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Device
+	for _, device := range devices {
+		if device.RegistrationTokenID == registrationToken {
+			result = append(result, device)
+		}
+	}
+	return result, nil
+}
+
 type DeviceRegistrationToken struct {
 	ID                   string               `json:"id" yaml:"id"`
 	CreatedAt            time.Time            `json:"createdAt" yaml:"createdAt"`
@@ -94,12 +172,72 @@ type DeviceRegistrationToken struct {
 	Labels               map[string]string    `json:"labels" yaml:"labels"`
 	EnvironmentVariables map[string]string    `json:"environmentVariables" yaml:"environmentVariables"`
 	NamingStrategy       DeviceNamingStrategy `json:"namingStrategy" yaml:"namingStrategy"`
+	ExpiresAt            *time.Time           `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"` // No registrations are accepted after this time
+	RevokedAt            *time.Time           `json:"revokedAt,omitempty" yaml:"revokedAt,omitempty"` // read-only, see RevokeRegistrationToken
+	Default              bool                 `json:"default,omitempty" yaml:"default,omitempty"`     // read-only, set on the token created with the project
 
 	DeviceCount int `json:"deviceCount" yaml:"deviceCount"` // read-only
 }
 
+// Usable returns an error if the token cannot be used to register a device at the given time because it
+// is revoked, expired or has reached its maximum number of registrations.
+func (t *DeviceRegistrationToken) Usable(now time.Time) error {
+	switch {
+	case t.RevokedAt != nil:
+		return fmt.Errorf("registration token '%s' was revoked at %s", t.Name, t.RevokedAt.Format(time.RFC3339))
+	case t.ExpiresAt != nil && !now.Before(*t.ExpiresAt):
+		return fmt.Errorf("registration token '%s' expired at %s", t.Name, t.ExpiresAt.Format(time.RFC3339))
+	case t.MaxRegistrations != nil && t.DeviceCount >= *t.MaxRegistrations:
+		return fmt.Errorf("registration token '%s' reached its limit of %d registrations", t.Name, *t.MaxRegistrations)
+	}
+	return nil
+}
+
+// DefaultAgentInstallScriptURL is the location of the Synpse agent install script.
+const DefaultAgentInstallScriptURL = "https://downloads.synpse.net/install.sh"
+
+// AgentInstallOptions configures the generated agent install command.
+type AgentInstallOptions struct {
+	// ControllerURL is the API address the agent connects to. Defaults to APIURL.
+	ControllerURL string
+	// InstallScriptURL defaults to DefaultAgentInstallScriptURL.
+	InstallScriptURL string
+}
+
+// InstallCommand returns a shell command that installs the agent and registers the device with the token.
+func (t *DeviceRegistrationToken) InstallCommand(opts AgentInstallOptions) string {
+	if opts.ControllerURL == "" {
+		opts.ControllerURL = APIURL
+	}
+	if opts.InstallScriptURL == "" {
+		opts.InstallScriptURL = DefaultAgentInstallScriptURL
+	}
+
+	return fmt.Sprintf("curl -fsSL %s | AGENT_PROJECT=%s AGENT_REGISTRATION_TOKEN=%s AGENT_CONTROLLER_URI=%s bash",
+		shellQuote(opts.InstallScriptURL), shellQuote(t.ProjectID), shellQuote(t.ID), shellQuote(opts.ControllerURL))
+}
+
+// CloudInit returns a cloud-init user data document that installs the agent on first boot.
+func (t *DeviceRegistrationToken) CloudInit(opts AgentInstallOptions) string {
+	command := t.InstallCommand(opts)
+	return "#cloud-config\nruncmd:\n  - '" + strings.ReplaceAll(command, "'", "''") + "'\n"
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DeviceNamingStrategy defines how devices registering with a token are named.
 type DeviceNamingStrategy struct {
 	Type DeviceNamingStrategyType `json:"type" yaml:"type"`
+
+	// Prefix is prepended to names generated by the fromHostname and fromField strategies.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Field is the device info field used by the fromField strategy.
+	Field DeviceNamingField `json:"field,omitempty" yaml:"field,omitempty"`
+	// Template is used by the template strategy, e.g. "store-{{label.site}}-{{serial}}". Supported
+	// placeholders are the DeviceNamingField values and "label.<key>" for registration token labels.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
 }
 
 type DeviceNamingStrategyType string
@@ -107,4 +245,115 @@ type DeviceNamingStrategyType string
 const (
 	DeviceNamingStrategyTypeDefault      DeviceNamingStrategyType = "default"
 	DeviceNamingStrategyTypeFromHostname DeviceNamingStrategyType = "fromHostname"
+	DeviceNamingStrategyTypeFromField    DeviceNamingStrategyType = "fromField"
+	DeviceNamingStrategyTypeTemplate     DeviceNamingStrategyType = "template"
 )
+
+// DeviceNamingField is a device info field that device names can be generated from.
+type DeviceNamingField string
+
+const (
+	DeviceNamingFieldHostname     DeviceNamingField = "hostname"
+	DeviceNamingFieldMACAddress   DeviceNamingField = "mac"
+	DeviceNamingFieldSerialNumber DeviceNamingField = "serial"
+	DeviceNamingFieldDeviceID     DeviceNamingField = "deviceId"
+	DeviceNamingFieldArchitecture DeviceNamingField = "architecture"
+)
+
+var deviceNamingFields = map[DeviceNamingField]func(info DeviceInfo) string{
+	DeviceNamingFieldHostname:     func(info DeviceInfo) string { return info.Hostname },
+	DeviceNamingFieldMACAddress:   func(info DeviceInfo) string { return info.MACAddress },
+	DeviceNamingFieldSerialNumber: func(info DeviceInfo) string { return info.SerialNumber },
+	DeviceNamingFieldDeviceID:     func(info DeviceInfo) string { return info.DeviceID },
+	DeviceNamingFieldArchitecture: func(info DeviceInfo) string { return info.Architecture },
+}
+
+const deviceNamingLabelPrefix = "label."
+
+var (
+	deviceNamingPlaceholder = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+	deviceNameInvalidChars  = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// Validate checks that the strategy type is known and has the parameters it requires.
+func (s DeviceNamingStrategy) Validate() error {
+	switch s.Type {
+	case "", DeviceNamingStrategyTypeDefault, DeviceNamingStrategyTypeFromHostname:
+		return nil
+	case DeviceNamingStrategyTypeFromField:
+		if _, ok := deviceNamingFields[s.Field]; !ok {
+			return fmt.Errorf("unknown device naming field '%s'", s.Field)
+		}
+		return nil
+	case DeviceNamingStrategyTypeTemplate:
+		if strings.TrimSpace(s.Template) == "" {
+			return fmt.Errorf("device naming template not specified")
+		}
+		if strings.Count(s.Template, "{{") != len(deviceNamingPlaceholder.FindAllString(s.Template, -1)) {
+			return fmt.Errorf("malformed device naming template '%s'", s.Template)
+		}
+		for _, m := range deviceNamingPlaceholder.FindAllStringSubmatch(s.Template, -1) {
+			key := m[1]
+			if strings.HasPrefix(key, deviceNamingLabelPrefix) && len(key) > len(deviceNamingLabelPrefix) {
+				continue
+			}
+			if _, ok := deviceNamingFields[DeviceNamingField(key)]; !ok {
+				return fmt.Errorf("unknown placeholder '%s' in device naming template", key)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown device naming strategy '%s'", s.Type)
+}
+
+// PreviewName returns the name a device with the info would get when registering with a token that has
+// the strategy and labels. Names are lowercased and characters other than letters, digits and '-' are
+// replaced with '-'. The default strategy names are generated by the server, so an empty name is returned.
+func (s DeviceNamingStrategy) PreviewName(info DeviceInfo, labels map[string]string) (string, error) {
+	err := s.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	var name string
+	switch s.Type {
+	case "", DeviceNamingStrategyTypeDefault:
+		return "", nil
+	case DeviceNamingStrategyTypeFromHostname:
+		if info.Hostname == "" {
+			return "", fmt.Errorf("device has no hostname")
+		}
+		name = s.Prefix + info.Hostname
+	case DeviceNamingStrategyTypeFromField:
+		value := deviceNamingFields[s.Field](info)
+		if value == "" {
+			return "", fmt.Errorf("device has no %s", s.Field)
+		}
+		name = s.Prefix + value
+	case DeviceNamingStrategyTypeTemplate:
+		var missing []string
+		name = deviceNamingPlaceholder.ReplaceAllStringFunc(s.Template, func(placeholder string) string {
+			key := deviceNamingPlaceholder.FindStringSubmatch(placeholder)[1]
+
+			var value string
+			if strings.HasPrefix(key, deviceNamingLabelPrefix) {
+				value = labels[strings.TrimPrefix(key, deviceNamingLabelPrefix)]
+			} else {
+				value = deviceNamingFields[DeviceNamingField(key)](info)
+			}
+			if value == "" {
+				missing = append(missing, key)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return "", fmt.Errorf("device naming template values missing: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	name = strings.Trim(deviceNameInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		return "", fmt.Errorf("device naming strategy produced an empty name")
+	}
+	return name, nil
+}
@@ -2,11 +2,16 @@ package synpse
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/segmentio/ksuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 // TODO
@@ -79,3 +84,145 @@ func TestDeviceRegistrationTokens(t *testing.T) {
 func toInt(v int) *int {
 	return &v
 }
+
+func TestDeviceRegistrationTokenUsable(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   DeviceRegistrationToken
+		wantErr string
+	}{
+		{name: "no limits", token: DeviceRegistrationToken{Name: "t"}},
+		{name: "not expired", token: DeviceRegistrationToken{Name: "t", ExpiresAt: &future, MaxRegistrations: toInt(2), DeviceCount: 1}},
+		{name: "expired", token: DeviceRegistrationToken{Name: "t", ExpiresAt: &past}, wantErr: "registration token 't' expired at 2021-06-01T11:00:00Z"},
+		{name: "revoked", token: DeviceRegistrationToken{Name: "t", RevokedAt: &past}, wantErr: "registration token 't' was revoked at 2021-06-01T11:00:00Z"},
+		{name: "exhausted", token: DeviceRegistrationToken{Name: "t", MaxRegistrations: toInt(2), DeviceCount: 2}, wantErr: "registration token 't' reached its limit of 2 registrations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.token.Usable(now)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDeviceRegistrationTokenInstallCommand(t *testing.T) {
+	token := DeviceRegistrationToken{ID: "drt_1", ProjectID: "prj_1"}
+
+	command := token.InstallCommand(AgentInstallOptions{})
+	assert.Equal(t, "curl -fsSL 'https://downloads.synpse.net/install.sh' | AGENT_PROJECT='prj_1' AGENT_REGISTRATION_TOKEN='drt_1' AGENT_CONTROLLER_URI='https://cloud.synpse.net/api' bash", command)
+
+	var userData struct {
+		RunCmd []string `yaml:"runcmd"`
+	}
+	cloudInit := token.CloudInit(AgentInstallOptions{ControllerURL: "https://synpse.example.com/api"})
+	require.True(t, strings.HasPrefix(cloudInit, "#cloud-config\n"))
+	require.NoError(t, yaml.Unmarshal([]byte(cloudInit), &userData))
+	assert.Equal(t, []string{token.InstallCommand(AgentInstallOptions{ControllerURL: "https://synpse.example.com/api"})}, userData.RunCmd)
+}
+
+func TestDeviceNamingStrategyPreviewName(t *testing.T) {
+	info := DeviceInfo{
+		DeviceID:     "dev_1",
+		Hostname:     "Raspberry-Pi",
+		MACAddress:   "DC:A6:32:01:02:03",
+		SerialNumber: "10000000a1b2c3",
+		Architecture: "arm64",
+	}
+	labels := map[string]string{"site": "Vilnius 01"}
+
+	tests := []struct {
+		name     string
+		strategy DeviceNamingStrategy
+		want     string
+		wantErr  string
+	}{
+		{name: "default", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeDefault}},
+		{name: "hostname", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeFromHostname, Prefix: "pi-"}, want: "pi-raspberry-pi"},
+		{name: "mac", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeFromField, Field: DeviceNamingFieldMACAddress}, want: "dc-a6-32-01-02-03"},
+		{name: "serial with prefix", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeFromField, Field: DeviceNamingFieldSerialNumber, Prefix: "sn-"}, want: "sn-10000000a1b2c3"},
+		{name: "template", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeTemplate, Template: "store-{{label.site}}-{{ serial }}"}, want: "store-vilnius-01-10000000a1b2c3"},
+		{name: "template missing label", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeTemplate, Template: "store-{{label.region}}"}, wantErr: "device naming template values missing: label.region"},
+		{name: "unknown placeholder", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeTemplate, Template: "{{uuid}}"}, wantErr: "unknown placeholder 'uuid' in device naming template"},
+		{name: "malformed template", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeTemplate, Template: "store-{{serial"}, wantErr: "malformed device naming template 'store-{{serial'"},
+		{name: "unknown field", strategy: DeviceNamingStrategy{Type: DeviceNamingStrategyTypeFromField, Field: "imei"}, wantErr: "unknown device naming field 'imei'"},
+		{name: "unknown type", strategy: DeviceNamingStrategy{Type: "random"}, wantErr: "unknown device naming strategy 'random'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := tt.strategy.PreviewName(info, labels)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, name)
+		})
+	}
+
+	_, err := DeviceNamingStrategy{Type: DeviceNamingStrategyTypeFromField, Field: DeviceNamingFieldSerialNumber}.PreviewName(DeviceInfo{}, nil)
+	assert.EqualError(t, err, "device has no serial")
+}
+
+func TestDefaultRegistrationToken(t *testing.T) {
+	marked := &DeviceRegistrationToken{ID: "drt-marked", ProjectID: "prj", Name: "fleet", Default: true}
+	other := &DeviceRegistrationToken{ID: "drt-other", ProjectID: "prj", Name: "default"}
+	foreign := &DeviceRegistrationToken{ID: "drt-foreign", ProjectID: "prj-other", Default: true}
+
+	tests := []struct {
+		name   string
+		tokens []*DeviceRegistrationToken
+		want   *DeviceRegistrationToken
+	}{
+		{"Marked", []*DeviceRegistrationToken{other, marked}, marked},
+		{"OtherProjectIgnored", []*DeviceRegistrationToken{foreign, other}, other},
+		{"OnlyToken", []*DeviceRegistrationToken{other}, other},
+		{"Ambiguous", []*DeviceRegistrationToken{other, {ID: "drt-2", ProjectID: "prj"}}, nil},
+		{"None", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, defaultRegistrationToken(tt.tokens, "prj"))
+		})
+	}
+}
+
+func TestRevokeAndListRegistrationTokenDevices(t *testing.T) {
+	var requests []string
+	client := getFakeServerClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/"+revokeURL):
+			_ = json.NewEncoder(w).Encode(DeviceRegistrationToken{ID: "drt-1", RevokedAt: &time.Time{}})
+		default:
+			// A server that ignores the filter returns devices of other tokens too
+			_ = json.NewEncoder(w).Encode([]*Device{
+				{ID: "dev-1", RegistrationTokenID: "drt-1"},
+				{ID: "dev-2", RegistrationTokenID: "drt-2"},
+			})
+		}
+	}))
+
+	token, err := client.RevokeRegistrationToken(context.Background(), "drt-1")
+	require.NoError(t, err)
+	assert.NotNil(t, token.RevokedAt)
+
+	devices, err := client.ListRegistrationTokenDevices(context.Background(), "drt-1")
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "dev-1", devices[0].ID)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "POST /projects/test-project/device-registration-tokens/drt-1/revoke?", requests[0])
+	assert.Contains(t, requests[1], "registrationTokenId=drt-1")
+}
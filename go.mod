@@ -3,10 +3,12 @@ module github.com/synpse-hq/synpse-go
 go 1.16
 
 require (
+	filippo.io/age v1.0.0-rc.3
 	github.com/function61/holepunch-server v0.0.0-20210312073819-8f5e8775e813
 	github.com/gorilla/websocket v1.4.2
 	github.com/pkg/errors v0.9.1
 	github.com/segmentio/ksuid v1.0.4
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/time v0.0.0-20211116232009-f0f3c7e86c11
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,179 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/synpse-hq/synpse-go"
+)
+
+var csvHeader = []string{
+	"id", "name", "status", "os", "os_version", "architecture", "cpu", "cpu_cores",
+	"docker_version", "docker_health", "privileged_enabled", "agent_version", "last_seen_at", "labels",
+}
+
+// WriteCSV writes records as CSV with a header row. Labels are written as "key=value" pairs separated by ';'.
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		var lastSeen string
+		if !r.LastSeenAt.IsZero() {
+			lastSeen = r.LastSeenAt.UTC().Format(time.RFC3339)
+		}
+		err = cw.Write([]string{
+			r.ID, r.Name, r.Status, r.OS, r.OSVersion, r.Architecture, r.CPU, strconv.Itoa(r.CPUCores),
+			r.DockerVersion, r.DockerHealth, strconv.FormatBool(r.PrivilegedEnabled), r.AgentVersion, lastSeen,
+			formatLabels(r.Labels),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes one JSON encoded record per line.
+func WriteJSONLines(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		err := enc.Encode(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultPrometheusGrouping is used by WritePrometheus when no keys are given.
+var DefaultPrometheusGrouping = []Key{KeyStatus, KeyOS, KeyArchitecture, KeyAgentVersion}
+
+// WritePrometheus writes the inventory in Prometheus text exposition format: device counts grouped by the
+// keys, and per device info, online status and last seen time.
+func WritePrometheus(w io.Writer, records []Record, groupBy ...Key) error {
+	if len(groupBy) == 0 {
+		groupBy = DefaultPrometheusGrouping
+	}
+
+	pw := &prometheusWriter{w: w}
+
+	names := prometheusLabelNames(groupBy)
+
+	pw.header("synpse_devices", "Number of devices by attributes.")
+	for _, group := range GroupBy(records, groupBy...) {
+		labels := make([]string, 0, len(groupBy)*2)
+		for i, key := range groupBy {
+			labels = append(labels, names[i], group.Values[key])
+		}
+		pw.sample("synpse_devices", labels, strconv.Itoa(group.Devices))
+	}
+
+	pw.header("synpse_device_info", "Device attributes, always 1.")
+	for _, r := range records {
+		pw.sample("synpse_device_info", []string{
+			"id", r.ID, "name", r.Name, "os", r.OS, "os_version", r.OSVersion, "architecture", r.Architecture,
+			"agent_version", r.AgentVersion, "docker_version", r.DockerVersion, "docker_health", r.DockerHealth,
+		}, "1")
+	}
+
+	pw.header("synpse_device_online", "Whether the device is online.")
+	for _, r := range records {
+		online := "0"
+		if r.Status == string(synpse.DeviceStatusOnline) {
+			online = "1"
+		}
+		pw.sample("synpse_device_online", []string{"id", r.ID, "name", r.Name}, online)
+	}
+
+	pw.header("synpse_device_last_seen_timestamp_seconds", "Time the device was last seen, in seconds since epoch.")
+	for _, r := range records {
+		if r.LastSeenAt.IsZero() {
+			continue
+		}
+		pw.sample("synpse_device_last_seen_timestamp_seconds", []string{"id", r.ID, "name", r.Name}, strconv.FormatInt(r.LastSeenAt.Unix(), 10))
+	}
+
+	return pw.err
+}
+
+type prometheusWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *prometheusWriter) header(name, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// sample writes a sample, labels are name/value pairs.
+func (p *prometheusWriter) sample(name string, labels []string, value string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, prometheusLabelName(labels[i])+`="`+prometheusLabelValue.Replace(labels[i+1])+`"`)
+	}
+	p.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), value)
+}
+
+func (p *prometheusWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+var (
+	prometheusInvalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	prometheusLabelValue        = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func prometheusLabelName(name string) string {
+	name = prometheusInvalidLabelChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// prometheusLabelNames returns unique label names for the keys. Keys that sanitize to the same name, such
+// as the device labels "site-a" and "site_a", get a numeric suffix, as duplicate label names are invalid.
+func prometheusLabelNames(keys []Key) []string {
+	names := make([]string, len(keys))
+	used := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		base := prometheusLabelName(string(key))
+		name := base
+		for n := 2; used[name]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + labels[k]
+	}
+	return strings.Join(pairs, ";")
+}
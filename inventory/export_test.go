@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, testRecords()[2:]))
+
+	assert.Equal(t, "id,name,status,os,os_version,architecture,cpu,cpu_cores,docker_version,docker_health,privileged_enabled,agent_version,last_seen_at,labels\n"+
+		"dev_3,nuc,online,ubuntu,,amd64,,0,,,false,1.3.0,2021-06-01T12:00:00Z,gpu=true;site=a\n", buf.String())
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSONLines(&buf, testRecords()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"id":"dev_1"`)
+	assert.Contains(t, lines[0], `"labels":{"site":"a"}`)
+}
+
+func TestWritePrometheus(t *testing.T) {
+	records := testRecords()
+	records[1].Name = `pi "two"`
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, records, KeyArchitecture, LabelKey("site")))
+
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE synpse_devices gauge\n")
+	assert.Contains(t, out, `synpse_devices{architecture="arm",label_site="a"} 1`+"\n")
+	assert.Contains(t, out, `synpse_devices{architecture="amd64",label_site="a"} 1`+"\n")
+	assert.Contains(t, out, `synpse_device_online{id="dev_2",name="pi \"two\""} 0`+"\n")
+	assert.Contains(t, out, `synpse_device_last_seen_timestamp_seconds{id="dev_1",name="pi-1"} 1622548800`+"\n")
+	assert.NotContains(t, out, `synpse_device_last_seen_timestamp_seconds{id="dev_2"`)
+}
+
+func TestWritePrometheusLabelCollision(t *testing.T) {
+	records := []Record{{ID: "dev_1", Labels: map[string]string{"site-a": "riga", "site_a": "vilnius"}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, records, LabelKey("site-a"), LabelKey("site_a")))
+	assert.Contains(t, buf.String(), `synpse_devices{label_site_a="riga",label_site_a_2="vilnius"} 1`+"\n")
+}
+
+func TestPrometheusLabelNames(t *testing.T) {
+	assert.Equal(t, []string{"label_a_b", "label_a_b_2", "label_a_b_2_2", "os"},
+		prometheusLabelNames([]Key{LabelKey("a.b"), LabelKey("a-b"), LabelKey("a_b_2"), KeyOS}))
+}
+
+func TestPrometheusLabelName(t *testing.T) {
+	assert.Equal(t, "label_app_kubernetes_io_name", prometheusLabelName("label_app.kubernetes.io/name"))
+	assert.Equal(t, "_1abc", prometheusLabelName("1abc"))
+}
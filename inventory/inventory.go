@@ -0,0 +1,151 @@
+// Package inventory builds device inventory reports from the Synpse API and exports them as CSV,
+// JSON lines or Prometheus text exposition format.
+package inventory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/synpse-hq/synpse-go"
+)
+
+// Record is a flattened inventory entry of a single device.
+type Record struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Status            string            `json:"status"`
+	OS                string            `json:"os"`
+	OSVersion         string            `json:"osVersion"`
+	Architecture      string            `json:"architecture"`
+	CPU               string            `json:"cpu"`
+	CPUCores          int               `json:"cpuCores"`
+	DockerVersion     string            `json:"dockerVersion"`
+	DockerHealth      string            `json:"dockerHealth"`
+	PrivilegedEnabled bool              `json:"privilegedEnabled"`
+	AgentVersion      string            `json:"agentVersion"`
+	LastSeenAt        time.Time         `json:"lastSeenAt"`
+	Labels            map[string]string `json:"labels,omitempty"`
+}
+
+// FromDevice flattens the device into an inventory record.
+func FromDevice(device *synpse.Device) Record {
+	return Record{
+		ID:                device.ID,
+		Name:              device.Name,
+		Status:            string(device.Status),
+		OS:                device.Info.OSRelease.ID,
+		OSVersion:         device.Info.OSRelease.VersionID,
+		Architecture:      device.Info.Architecture,
+		CPU:               device.Info.CPUInfo.BrandName,
+		CPUCores:          device.Info.CPUInfo.LogicalCores,
+		DockerVersion:     device.Info.Docker.Version,
+		DockerHealth:      device.Info.Docker.Health,
+		PrivilegedEnabled: device.Info.Docker.PrivilegedEnabled,
+		AgentVersion:      device.Info.AgentVersion,
+		LastSeenAt:        device.LastSeenAt,
+		Labels:            device.Labels,
+	}
+}
+
+// Collect lists all devices matching the labels in the client's project and returns their records.
+func Collect(ctx context.Context, client *synpse.API, labels map[string]string) ([]Record, error) {
+	devices, err := client.ListAllDevices(ctx, &synpse.ListDevicesRequest{Labels: labels})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(devices))
+	for _, device := range devices {
+		records = append(records, FromDevice(device))
+	}
+	return records, nil
+}
+
+// Key is a record attribute that devices can be grouped by.
+type Key string
+
+const (
+	KeyStatus        Key = "status"
+	KeyOS            Key = "os"
+	KeyOSVersion     Key = "os_version"
+	KeyArchitecture  Key = "architecture"
+	KeyCPU           Key = "cpu"
+	KeyDockerVersion Key = "docker_version"
+	KeyDockerHealth  Key = "docker_health"
+	KeyAgentVersion  Key = "agent_version"
+)
+
+const labelKeyPrefix = "label_"
+
+// LabelKey groups devices by the value of a device label.
+func LabelKey(label string) Key {
+	return Key(labelKeyPrefix + label)
+}
+
+// Value returns the record value for the key.
+func (r *Record) Value(key Key) string {
+	switch key {
+	case KeyStatus:
+		return r.Status
+	case KeyOS:
+		return r.OS
+	case KeyOSVersion:
+		return r.OSVersion
+	case KeyArchitecture:
+		return r.Architecture
+	case KeyCPU:
+		return r.CPU
+	case KeyDockerVersion:
+		return r.DockerVersion
+	case KeyDockerHealth:
+		return r.DockerHealth
+	case KeyAgentVersion:
+		return r.AgentVersion
+	}
+	if strings.HasPrefix(string(key), labelKeyPrefix) {
+		return r.Labels[strings.TrimPrefix(string(key), labelKeyPrefix)]
+	}
+	return ""
+}
+
+// Group is the number of devices sharing the same values of the grouping keys.
+type Group struct {
+	Values  map[Key]string
+	Devices int
+}
+
+// GroupBy counts records by the values of the keys. Groups are sorted by device count, largest first.
+func GroupBy(records []Record, keys ...Key) []Group {
+	index := make(map[string]*Group)
+	var groups []*Group
+	for i := range records {
+		values := make(map[Key]string, len(keys))
+		parts := make([]string, len(keys))
+		for j, key := range keys {
+			values[key] = records[i].Value(key)
+			parts[j] = strconv.Quote(values[key])
+		}
+
+		id := strings.Join(parts, ",")
+		group, ok := index[id]
+		if !ok {
+			group = &Group{Values: values}
+			index[id] = group
+			groups = append(groups, group)
+		}
+		group.Devices++
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Devices > groups[j].Devices
+	})
+
+	result := make([]Group, len(groups))
+	for i, group := range groups {
+		result[i] = *group
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synpse-hq/synpse-go"
+)
+
+func testRecords() []Record {
+	lastSeen := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []Record{
+		{ID: "dev_1", Name: "pi-1", Status: "online", OS: "raspbian", Architecture: "arm", AgentVersion: "1.2.0", LastSeenAt: lastSeen, Labels: map[string]string{"site": "a"}},
+		{ID: "dev_2", Name: "pi-2", Status: "offline", OS: "raspbian", Architecture: "arm", AgentVersion: "1.2.0", Labels: map[string]string{"site": "b"}},
+		{ID: "dev_3", Name: "nuc", Status: "online", OS: "ubuntu", Architecture: "amd64", AgentVersion: "1.3.0", LastSeenAt: lastSeen, Labels: map[string]string{"site": "a", "gpu": "true"}},
+	}
+}
+
+func TestFromDevice(t *testing.T) {
+	record := FromDevice(&synpse.Device{
+		ID:     "dev_1",
+		Name:   "pi",
+		Status: synpse.DeviceStatusOnline,
+		Info: synpse.DeviceInfo{
+			AgentVersion: "1.2.0",
+			Architecture: "arm64",
+			OSRelease:    synpse.OSRelease{ID: "debian", VersionID: "11"},
+			Docker:       synpse.DockerInfo{Version: "20.10.5", Health: "healthy"},
+			CPUInfo:      synpse.CPUInfo{BrandName: "Cortex-A72", LogicalCores: 4},
+		},
+	})
+
+	assert.Equal(t, Record{
+		ID:            "dev_1",
+		Name:          "pi",
+		Status:        "online",
+		OS:            "debian",
+		OSVersion:     "11",
+		Architecture:  "arm64",
+		CPU:           "Cortex-A72",
+		CPUCores:      4,
+		DockerVersion: "20.10.5",
+		DockerHealth:  "healthy",
+		AgentVersion:  "1.2.0",
+	}, record)
+}
+
+func TestGroupBy(t *testing.T) {
+	groups := GroupBy(testRecords(), KeyOS, LabelKey("site"))
+
+	assert.Equal(t, []Group{
+		{Values: map[Key]string{KeyOS: "raspbian", "label_site": "a"}, Devices: 1},
+		{Values: map[Key]string{KeyOS: "raspbian", "label_site": "b"}, Devices: 1},
+		{Values: map[Key]string{KeyOS: "ubuntu", "label_site": "a"}, Devices: 1},
+	}, groups)
+
+	groups = GroupBy(testRecords(), KeyArchitecture)
+	assert.Equal(t, []Group{
+		{Values: map[Key]string{KeyArchitecture: "arm"}, Devices: 2},
+		{Values: map[Key]string{KeyArchitecture: "amd64"}, Devices: 1},
+	}, groups)
+}
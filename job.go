@@ -42,6 +42,10 @@ func (api *API) CreateJob(ctx context.Context, namespace string, job Job) (*Job,
 		return nil, fmt.Errorf("namespace not selected")
 	}
 
+	if err := job.Scheduling.Validate(); err != nil {
+		return nil, fmt.Errorf("job '%s' scheduling: %w", job.Name, err)
+	}
+
 	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, jobsURL), job)
 	if err != nil {
 		return nil, err
@@ -71,6 +75,10 @@ func (api *API) UpdateJob(ctx context.Context, namespace string, j Job) (*Job, e
 		return nil, fmt.Errorf("job ID or name must be specified")
 	}
 
+	if err := j.Scheduling.Validate(); err != nil {
+		return nil, fmt.Errorf("job '%s' scheduling: %w", j.Name, err)
+	}
+
 	resp, _, err := api.makeRequestContext(ctx, http.MethodPatch, getURL(api.BaseURL, projectsURL, api.ProjectID, namespacesURL, namespace, jobsURL, jobIdentifier), j)
 	if err != nil {
 		return nil, err
@@ -247,6 +255,29 @@ type WorkloadStatus struct {
 	Annotations map[string]string `json:"annotations" yaml:"annotations"`
 	StartedAt   time.Time         `json:"startedAt" yaml:"startedAt"`
 	CompletedAt time.Time         `json:"completedAt" yaml:"completedAt"`
+	Health      HealthState       `json:"health,omitempty" yaml:"health,omitempty"` // Empty when the container has no health check
 }
 
 type WorkloadStatuses []WorkloadStatus
+
+// HealthState is the container health check state reported by the runtime
+type HealthState string
+
+const (
+	HealthStateNone      HealthState = "none"
+	HealthStateStarting  HealthState = "starting"
+	HealthStateHealthy   HealthState = "healthy"
+	HealthStateUnhealthy HealthState = "unhealthy"
+)
+
+// Healthy returns false if any of the workloads reports an unhealthy or still starting health check.
+// Workloads without health checks are considered healthy.
+func (s WorkloadStatuses) Healthy() bool {
+	for _, status := range s {
+		switch status.Health {
+		case HealthStateStarting, HealthStateUnhealthy:
+			return false
+		}
+	}
+	return true
+}
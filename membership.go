@@ -0,0 +1,99 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ListMemberships returns members of the current project together with their users and roles.
+func (api *API) ListMemberships(ctx context.Context) ([]*Membership, error) {
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, membershipsURL+"?full"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Membership
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return result, nil
+}
+
+// InviteMemberRequest is used to add a user to the current project.
+type InviteMemberRequest struct {
+	Email   string   `json:"email" yaml:"email"`
+	RoleIDs []string `json:"roleIds,omitempty" yaml:"roleIds,omitempty"` // Roles bound to the new membership
+}
+
+// InviteMember adds a user to the current project by email, optionally binding roles to the membership.
+func (api *API) InviteMember(ctx context.Context, req InviteMemberRequest) (*Membership, error) {
+	if req.Email == "" {
+		return nil, fmt.Errorf("email not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, membershipsURL), req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Membership
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// RemoveMember removes the user from the current project.
+func (api *API) RemoveMember(ctx context.Context, userID string) error {
+	if userID == "" {
+		return fmt.Errorf("user ID not specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, api.ProjectID, membershipsURL, userID), nil)
+	return err
+}
+
+// ListMembershipRoles returns roles bound to the user's membership in the current project.
+func (api *API) ListMembershipRoles(ctx context.Context, userID string) ([]*Role, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, membershipsURL, userID, rolesURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Role
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return result, nil
+}
+
+// BindMembershipRole binds the role to the user's membership in the current project.
+func (api *API) BindMembershipRole(ctx context.Context, userID, roleID string) error {
+	if userID == "" || roleID == "" {
+		return fmt.Errorf("user ID and role ID must be specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodPut, getURL(api.BaseURL, projectsURL, api.ProjectID, membershipsURL, userID, rolesURL, roleID), nil)
+	return err
+}
+
+// UnbindMembershipRole removes the role from the user's membership in the current project.
+func (api *API) UnbindMembershipRole(ctx context.Context, userID, roleID string) error {
+	if userID == "" || roleID == "" {
+		return fmt.Errorf("user ID and role ID must be specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, api.ProjectID, membershipsURL, userID, rolesURL, roleID), nil)
+	return err
+}
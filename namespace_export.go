@@ -0,0 +1,504 @@
+package synpse
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ExportSecretsMode specifies how secret data is written to namespace exports.
+type ExportSecretsMode string
+
+const (
+	ExportSecretsRedact  ExportSecretsMode = "redact"  // Default, secrets are exported without data
+	ExportSecretsInclude ExportSecretsMode = "include" // Secret data is exported in plain text
+	ExportSecretsEncrypt ExportSecretsMode = "encrypt" // Secret data is encrypted with ExportNamespaceOptions.Encryptor
+)
+
+const namespaceBundleVersion = 1
+
+// ExportNamespaceOptions configures ExportNamespace.
+type ExportNamespaceOptions struct {
+	Secrets   ExportSecretsMode
+	Encryptor SecretEncryptor // Required for ExportSecretsEncrypt
+}
+
+// ImportNamespaceOptions configures ImportNamespace.
+type ImportNamespaceOptions struct {
+	// Namespace is the target namespace name, defaults to the exported namespace name. The namespace is
+	// created if it doesn't exist. To import into another project, use a client for that project.
+	Namespace string
+	// Names maps exported application, job and secret names to new names. Secret references in
+	// application and job specs and the namespace registry authentication are updated accordingly.
+	Names map[string]string
+	// Encryptor decrypts secrets exported with ExportSecretsEncrypt.
+	Encryptor SecretEncryptor
+}
+
+// ImportNamespaceResult lists imported resources by their new names. If the import fails, the resources it
+// created are deleted again and the result only lists the ones that could not be deleted.
+type ImportNamespaceResult struct {
+	Namespace        string
+	NamespaceCreated bool // The namespace did not exist and was created by the import
+	Secrets          []string
+	Applications     []string
+	Jobs             []string
+	// RedactedSecrets were exported without data and have not been created. Create them before
+	// the applications that reference them are deployed.
+	RedactedSecrets []string
+}
+
+type namespaceBundleManifest struct {
+	Version    int       `json:"version"`
+	Namespace  string    `json:"namespace"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+type namespaceBundleSecret struct {
+	Name      string     `json:"name"`
+	Type      SecretType `json:"type"`
+	Data      string     `json:"data,omitempty"` // Base64 encoded or client side encrypted data
+	Redacted  bool       `json:"redacted,omitempty"`
+	Encrypted bool       `json:"encrypted,omitempty"`
+}
+
+type namespaceBundle struct {
+	Manifest     namespaceBundleManifest
+	Namespace    Namespace
+	Secrets      []namespaceBundleSecret
+	Applications []Application
+	Jobs         []Job
+}
+
+// ExportNamespace writes the namespace configuration, applications, jobs and secrets to w as a tar
+// archive of JSON files. Server computed fields such as IDs, versions and statuses are not exported.
+func (api *API) ExportNamespace(ctx context.Context, name string, w io.Writer, opts ExportNamespaceOptions) error {
+	if opts.Secrets == "" {
+		opts.Secrets = ExportSecretsRedact
+	}
+	if opts.Secrets == ExportSecretsEncrypt && opts.Encryptor == nil {
+		return fmt.Errorf("encryptor is required to export encrypted secrets")
+	}
+
+	ns, err := api.GetNamespace(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	bundle := &namespaceBundle{
+		Manifest: namespaceBundleManifest{
+			Version:    namespaceBundleVersion,
+			Namespace:  ns.Name,
+			ExportedAt: time.Now().UTC(),
+		},
+		Namespace: Namespace{Name: ns.Name, Config: ns.Config},
+	}
+
+	secrets, err := api.ListSecrets(ctx, &ListSecretsRequest{Namespace: name})
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, s := range secrets {
+		exported := namespaceBundleSecret{Name: s.Name, Type: s.Type}
+
+		switch opts.Secrets {
+		case ExportSecretsRedact:
+			exported.Redacted = true
+		case ExportSecretsInclude, ExportSecretsEncrypt:
+			secret := s
+			if secret.Data == "" {
+				// Only get secrets the list returned without data
+				secret, err = api.GetSecret(ctx, name, s.Name)
+				if err != nil {
+					return fmt.Errorf("failed to get secret '%s': %w", s.Name, err)
+				}
+			}
+			data, err := secret.Bytes()
+			if err != nil {
+				return err
+			}
+
+			if opts.Secrets == ExportSecretsEncrypt {
+				exported.Data, err = encryptSecretData(opts.Encryptor, data)
+				if err != nil {
+					return fmt.Errorf("failed to encrypt secret '%s': %w", s.Name, err)
+				}
+				exported.Encrypted = true
+			} else {
+				exported.Data = base64.StdEncoding.EncodeToString(data)
+			}
+		default:
+			return fmt.Errorf("unknown secrets export mode '%s'", opts.Secrets)
+		}
+
+		bundle.Secrets = append(bundle.Secrets, exported)
+	}
+
+	applications, err := api.ListApplications(ctx, &ListApplicationsRequest{Namespace: name})
+	if err != nil {
+		return fmt.Errorf("failed to list applications: %w", err)
+	}
+	for _, application := range applications {
+		bundle.Applications = append(bundle.Applications, exportedApplication(*application))
+	}
+
+	jobs, err := api.ListJobs(ctx, ListJobsRequest{Namespace: name})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, job := range jobs {
+		bundle.Jobs = append(bundle.Jobs, exportedJob(*job))
+	}
+
+	return writeNamespaceBundle(w, bundle)
+}
+
+// ImportNamespace recreates an exported namespace. Secrets are created first, then applications and jobs.
+// The import is not atomic on the server, so if creating any resource fails, the resources created so far
+// are deleted and the namespace configuration is restored. A namespace created by the import is deleted
+// as well. Resources that could not be deleted are listed in the result and the returned error.
+func (api *API) ImportNamespace(ctx context.Context, r io.Reader, opts ImportNamespaceOptions) (*ImportNamespaceResult, error) {
+	bundle, err := readNamespaceBundle(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rename := func(name string) string {
+		if renamed, ok := opts.Names[name]; ok {
+			return renamed
+		}
+		return name
+	}
+
+	target := opts.Namespace
+	if target == "" {
+		target = bundle.Namespace.Name
+	}
+	result := &ImportNamespaceResult{Namespace: target}
+
+	var config *NamespaceConfig
+	if bundle.Namespace.Config != nil {
+		c := *bundle.Namespace.Config
+		c.RegistryAuth.FromSecret = renameRef(c.RegistryAuth.FromSecret, rename)
+		config = &c
+	}
+
+	// previous is the namespace before its configuration was updated, restored on failure
+	var previous *Namespace
+
+	existing, err := api.GetNamespace(ctx, target)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		_, err = api.CreateNamespace(ctx, Namespace{Name: target, Config: config})
+		if err != nil {
+			return result, fmt.Errorf("failed to create namespace: %w", err)
+		}
+		result.NamespaceCreated = true
+	case err != nil:
+		return result, err
+	case config != nil:
+		_, err = api.UpdateNamespace(ctx, Namespace{Name: target, Config: config})
+		if err != nil {
+			return result, fmt.Errorf("failed to update namespace: %w", err)
+		}
+		previous = existing
+	}
+
+	err = api.importNamespaceResources(ctx, bundle, target, opts, rename, result)
+	if err != nil {
+		return result, api.rollbackImport(ctx, result, previous, err)
+	}
+	return result, nil
+}
+
+// importNamespaceResources creates the bundle secrets, applications and jobs, recording them in result.
+func (api *API) importNamespaceResources(ctx context.Context, bundle *namespaceBundle, target string, opts ImportNamespaceOptions, rename func(string) string, result *ImportNamespaceResult) error {
+	for _, s := range bundle.Secrets {
+		if s.Redacted {
+			result.RedactedSecrets = append(result.RedactedSecrets, rename(s.Name))
+			continue
+		}
+
+		secret := Secret{Name: rename(s.Name), Type: s.Type}
+		if s.Encrypted {
+			if opts.Encryptor == nil {
+				return fmt.Errorf("secret '%s' is encrypted, encryptor is required", s.Name)
+			}
+			keyID, ciphertext, _, err := parseEncryptedSecretData(s.Data)
+			if err != nil {
+				return fmt.Errorf("secret '%s': %w", s.Name, err)
+			}
+			plaintext, err := opts.Encryptor.Decrypt(keyID, ciphertext)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt secret '%s': %w", s.Name, err)
+			}
+			secret.Data = base64.StdEncoding.EncodeToString(plaintext)
+		} else {
+			secret.Data = s.Data
+		}
+		secret.Encoding = SecretEncodingBase64
+
+		_, err := api.CreateSecret(ctx, target, secret)
+		if err != nil {
+			return fmt.Errorf("failed to create secret '%s': %w", secret.Name, err)
+		}
+		result.Secrets = append(result.Secrets, secret.Name)
+	}
+
+	for _, application := range bundle.Applications {
+		application.Name = rename(application.Name)
+		application.Spec.ContainerSpec = renameContainerSecretRefs(application.Spec.ContainerSpec, rename)
+		if application.Spec.SystemdSpec != nil {
+			systemd := *application.Spec.SystemdSpec
+			systemd.Environment = renameEnvironmentSecretRefs(systemd.Environment, rename)
+			systemd.Files = append([]SystemdFile(nil), systemd.Files...)
+			for i := range systemd.Files {
+				systemd.Files[i].FromSecret = renameRef(systemd.Files[i].FromSecret, rename)
+			}
+			application.Spec.SystemdSpec = &systemd
+		}
+
+		_, err := api.CreateApplication(ctx, target, application)
+		if err != nil {
+			return fmt.Errorf("failed to create application '%s': %w", application.Name, err)
+		}
+		result.Applications = append(result.Applications, application.Name)
+	}
+
+	for _, job := range bundle.Jobs {
+		job.Name = rename(job.Name)
+		job.Spec.ContainerSpec = renameContainerSecretRefs(job.Spec.ContainerSpec, rename)
+
+		_, err := api.CreateJob(ctx, target, job)
+		if err != nil {
+			return fmt.Errorf("failed to create job '%s': %w", job.Name, err)
+		}
+		result.Jobs = append(result.Jobs, job.Name)
+	}
+
+	return nil
+}
+
+// rollbackImport deletes the resources listed in result, restores the previous namespace configuration and
+// deletes the namespace if the import created it. Every step is attempted even if an earlier one fails, and
+// result is left with the resources that could not be deleted. The returned error wraps the cause.
+func (api *API) rollbackImport(ctx context.Context, result *ImportNamespaceResult, previous *Namespace, cause error) error {
+	var failures []string
+
+	remove := func(kind string, names []string, del func(ctx context.Context, namespace, name string) error) []string {
+		var remaining []string
+		for _, name := range names {
+			err := del(ctx, result.Namespace, name)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				failures = append(failures, fmt.Sprintf("failed to delete %s '%s': %v", kind, name, err))
+				remaining = append(remaining, name)
+			}
+		}
+		return remaining
+	}
+
+	// Workloads are deleted before the secrets they reference
+	result.Jobs = remove("job", result.Jobs, api.DeleteJob)
+	result.Applications = remove("application", result.Applications, api.DeleteApplication)
+	result.Secrets = remove("secret", result.Secrets, api.DeleteSecret)
+
+	if previous != nil {
+		_, err := api.UpdateNamespace(ctx, Namespace{Name: previous.Name, Config: previous.Config})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to restore namespace configuration: %v", err))
+		}
+	}
+
+	if result.NamespaceCreated && len(failures) == 0 {
+		err := api.DeleteNamespace(ctx, result.Namespace)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			failures = append(failures, fmt.Sprintf("failed to delete namespace: %v", err))
+		} else {
+			result.NamespaceCreated = false
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("import rollback incomplete (%s): %w", strings.Join(failures, "; "), cause)
+	}
+	return fmt.Errorf("import rolled back: %w", cause)
+}
+
+// exportedApplication strips server computed fields.
+func exportedApplication(a Application) Application {
+	return Application{
+		Name:        a.Name,
+		Description: a.Description,
+		Type:        a.Type,
+		Scheduling:  a.Scheduling,
+		Spec:        a.Spec,
+	}
+}
+
+// exportedJob strips server computed fields.
+func exportedJob(j Job) Job {
+	return Job{
+		Name:         j.Name,
+		Description:  j.Description,
+		Scheduling:   j.Scheduling,
+		Spec:         j.Spec,
+		DesiredState: j.DesiredState,
+	}
+}
+
+func renameRef(name string, rename func(string) string) string {
+	if name == "" {
+		return ""
+	}
+	return rename(name)
+}
+
+func renameEnvironmentSecretRefs(envs Environments, rename func(string) string) Environments {
+	if envs == nil {
+		return nil
+	}
+	renamed := make(Environments, len(envs))
+	for i, env := range envs {
+		env.FromSecret = renameRef(env.FromSecret, rename)
+		renamed[i] = env
+	}
+	return renamed
+}
+
+// renameContainerSecretRefs returns a copy of the containers with secret references renamed.
+func renameContainerSecretRefs(containers []ContainerSpec, rename func(string) string) []ContainerSpec {
+	if containers == nil {
+		return nil
+	}
+	renamed := make([]ContainerSpec, len(containers))
+	for i, c := range containers {
+		c.Environment = renameEnvironmentSecretRefs(c.Environment, rename)
+		if c.Secrets != nil {
+			secrets := make([]SecretRef, len(c.Secrets))
+			for j, s := range c.Secrets {
+				s.Name = rename(s.Name)
+				secrets[j] = s
+			}
+			c.Secrets = secrets
+		}
+		if c.Auth != nil {
+			auth := *c.Auth
+			auth.FromSecret = renameRef(auth.FromSecret, rename)
+			c.Auth = &auth
+		}
+		renamed[i] = c
+	}
+	return renamed
+}
+
+func writeNamespaceBundle(w io.Writer, bundle *namespaceBundle) error {
+	tw := tar.NewWriter(w)
+
+	write := func(name string, v interface{}) error {
+		bts, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(bts)),
+			ModTime: bundle.Manifest.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(bts)
+		return err
+	}
+
+	if err := write("manifest.json", bundle.Manifest); err != nil {
+		return err
+	}
+	if err := write("namespace.json", bundle.Namespace); err != nil {
+		return err
+	}
+	for _, s := range bundle.Secrets {
+		if err := write("secrets/"+s.Name+".json", s); err != nil {
+			return err
+		}
+	}
+	for _, a := range bundle.Applications {
+		if err := write("applications/"+a.Name+".json", a); err != nil {
+			return err
+		}
+	}
+	for _, j := range bundle.Jobs {
+		if err := write("jobs/"+j.Name+".json", j); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+func readNamespaceBundle(r io.Reader) (*namespaceBundle, error) {
+	var (
+		bundle      namespaceBundle
+		hasManifest bool
+		tr          = tar.NewReader(r)
+	)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read namespace export: %w", err)
+		}
+
+		bts, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(hdr.Name)
+		dir := path.Dir(name)
+		switch {
+		case name == "manifest.json":
+			err = json.Unmarshal(bts, &bundle.Manifest)
+			hasManifest = true
+		case name == "namespace.json":
+			err = json.Unmarshal(bts, &bundle.Namespace)
+		case dir == "secrets" && strings.HasSuffix(name, ".json"):
+			var s namespaceBundleSecret
+			err = json.Unmarshal(bts, &s)
+			bundle.Secrets = append(bundle.Secrets, s)
+		case dir == "applications" && strings.HasSuffix(name, ".json"):
+			var a Application
+			err = json.Unmarshal(bts, &a)
+			bundle.Applications = append(bundle.Applications, a)
+		case dir == "jobs" && strings.HasSuffix(name, ".json"):
+			var j Job
+			err = json.Unmarshal(bts, &j)
+			bundle.Jobs = append(bundle.Jobs, j)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode '%s': %w", hdr.Name, err)
+		}
+	}
+
+	if !hasManifest {
+		return nil, fmt.Errorf("invalid namespace export: manifest.json not found")
+	}
+	if bundle.Manifest.Version != namespaceBundleVersion {
+		return nil, fmt.Errorf("unsupported namespace export version %d", bundle.Manifest.Version)
+	}
+
+	return &bundle, nil
+}
@@ -0,0 +1,166 @@
+package synpse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceBundle(t *testing.T) {
+	bundle := &namespaceBundle{
+		Manifest: namespaceBundleManifest{
+			Version:    namespaceBundleVersion,
+			Namespace:  "default",
+			ExportedAt: time.Now().UTC().Truncate(time.Second),
+		},
+		Namespace: Namespace{
+			Name:   "default",
+			Config: &NamespaceConfig{RegistryAuth: DockerAuth{Username: "bot", FromSecret: "registry"}},
+		},
+		Secrets: []namespaceBundleSecret{
+			{Name: "registry", Type: SecretTypeEnvironment, Data: "czNjcjN0"},
+			{Name: "db", Type: SecretTypeEnvironment, Redacted: true},
+		},
+		Applications: []Application{
+			exportedApplication(Application{
+				ID:               "app_1",
+				Version:          4,
+				Name:             "hello",
+				DeploymentStatus: ApplicationDeploymentStatus{Available: 2, Total: 2},
+				Spec: ApplicationSpec{
+					ContainerSpec: []ContainerSpec{{Name: "hello", Image: "hello"}},
+				},
+			}),
+		},
+		Jobs: []Job{
+			exportedJob(Job{ID: "job_1", Name: "migrate", State: DeviceJobStateSucceeded}),
+		},
+	}
+
+	assert.Empty(t, bundle.Applications[0].ID)
+	assert.Zero(t, bundle.Applications[0].Version)
+	assert.Zero(t, bundle.Applications[0].DeploymentStatus)
+	assert.Empty(t, bundle.Jobs[0].State)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeNamespaceBundle(&buf, bundle))
+
+	decoded, err := readNamespaceBundle(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, bundle.Manifest, decoded.Manifest)
+	assert.Equal(t, bundle.Namespace, decoded.Namespace)
+	assert.ElementsMatch(t, bundle.Secrets, decoded.Secrets)
+	assert.Equal(t, bundle.Applications, decoded.Applications)
+	assert.Equal(t, bundle.Jobs, decoded.Jobs)
+
+	_, err = readNamespaceBundle(bytes.NewReader(nil))
+	assert.Error(t, err, "expected missing manifest error")
+}
+
+func TestRenameContainerSecretRefs(t *testing.T) {
+	containers := []ContainerSpec{
+		{
+			Auth:        &DockerAuth{FromSecret: "registry"},
+			Environment: Environments{{Name: "PASSWORD", FromSecret: "db"}, {Name: "HOST", Value: "db"}},
+			Secrets:     []SecretRef{{Name: "db", Filepath: "/run/db"}},
+		},
+	}
+	rename := func(name string) string {
+		if name == "db" {
+			return "db-copy"
+		}
+		return name
+	}
+
+	renamed := renameContainerSecretRefs(containers, rename)
+	assert.Equal(t, "registry", renamed[0].Auth.FromSecret)
+	assert.Equal(t, "db-copy", renamed[0].Environment[0].FromSecret)
+	assert.Equal(t, "", renamed[0].Environment[1].FromSecret)
+	assert.Equal(t, "db", renamed[0].Environment[1].Value)
+	assert.Equal(t, "db-copy", renamed[0].Secrets[0].Name)
+
+	// Original containers are not modified
+	assert.Equal(t, "db", containers[0].Environment[0].FromSecret)
+	assert.Equal(t, "db", containers[0].Secrets[0].Name)
+}
+
+func TestImportNamespaceRollback(t *testing.T) {
+	bundle := &namespaceBundle{
+		Manifest:  namespaceBundleManifest{Version: namespaceBundleVersion, Namespace: "staging"},
+		Namespace: Namespace{Name: "staging"},
+		Secrets:   []namespaceBundleSecret{{Name: "db", Type: SecretTypeEnvironment, Data: "czNjcjN0"}},
+		Applications: []Application{
+			{Name: "api", Spec: ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "api", Image: "api"}}}},
+			{Name: "web", Spec: ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "web", Image: "web"}}}},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeNamespaceBundle(&buf, bundle))
+
+	var deleted []string
+	client := getFakeServerClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/projects/test-project/namespaces")
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodDelete:
+			deleted = append(deleted, p)
+		case http.MethodPost:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["name"] == "web" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("invalid application"))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(body)
+		}
+	}))
+
+	result, err := client.ImportNamespace(context.Background(), &buf, ImportNamespaceOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "import rolled back")
+	assert.Contains(t, err.Error(), "failed to create application 'web'")
+
+	assert.Equal(t, []string{"/staging/applications/api", "/staging/secrets/db", "/staging"}, deleted)
+	assert.Empty(t, result.Secrets)
+	assert.Empty(t, result.Applications)
+	assert.False(t, result.NamespaceCreated)
+}
+
+func TestExportNamespaceSecretsFromList(t *testing.T) {
+	var requests []string
+	client := getFakeServerClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/projects/test-project/namespaces")
+		requests = append(requests, p)
+		switch p {
+		case "/default":
+			_ = json.NewEncoder(w).Encode(Namespace{Name: "default"})
+		case "/default/secrets":
+			_ = json.NewEncoder(w).Encode([]*Secret{
+				{Name: "db", Type: SecretTypeEnvironment, Data: "czNjcjN0"},
+				{Name: "token", Type: SecretTypeEnvironment, Data: "dG9rZW4="},
+			})
+		default:
+			_, _ = w.Write([]byte("[]"))
+		}
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, client.ExportNamespace(context.Background(), "default", &buf, ExportNamespaceOptions{Secrets: ExportSecretsInclude}))
+	assert.Equal(t, []string{"/default", "/default/secrets", "/default/applications", "/default/jobs"}, requests, "secrets should not be requested one by one")
+
+	bundle, err := readNamespaceBundle(&buf)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []namespaceBundleSecret{
+		{Name: "db", Type: SecretTypeEnvironment, Data: "czNjcjN0"},
+		{Name: "token", Type: SecretTypeEnvironment, Data: "dG9rZW4="},
+	}, bundle.Secrets)
+}
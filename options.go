@@ -68,6 +68,37 @@ func WithLogger(logger Logger) Option {
 	}
 }
 
+// WithRevisionStore sets the store for client-side application revision history, which is used when the
+// server doesn't retain history. Before each application update the current application is saved to the
+// store, which is then used for listing revisions and rollbacks. By default revisions are kept in the user
+// cache directory, see NewDirRevisionStore. A nil store disables client-side history.
+func WithRevisionStore(store RevisionStore) Option {
+	return func(api *API) error {
+		api.revisionStore = store
+		return nil
+	}
+}
+
+// WithSecretEncryptor enables client-side secret encryption. Secret data is encrypted in CreateSecret and
+// UpdateSecret and decrypted in GetSecret and ListSecrets, see AESGCMEncryptor and AgeEncryptor. Workloads
+// cannot use client-side encrypted secrets, see SecretEncryptor.
+func WithSecretEncryptor(encryptor SecretEncryptor) Option {
+	return func(api *API) error {
+		api.secretEncryptor = encryptor
+		return nil
+	}
+}
+
+// WithCredentialValidation makes New and NewWithProject call WhoAmI to check the access key, and for
+// NewWithProject that the caller can access the project, returning an error instead of a client that
+// fails on the first request.
+func WithCredentialValidation() Option {
+	return func(api *API) error {
+		api.validateOnCreate = true
+		return nil
+	}
+}
+
 // parseOptions parses the supplied options functions and returns a configured
 // *API instance.
 func (api *API) parseOptions(opts ...Option) error {
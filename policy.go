@@ -0,0 +1,109 @@
+package synpse
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decision is the result of an access evaluation.
+type Decision struct {
+	Allowed bool
+	// Role and Rule that decided the outcome. Both are empty when no rule matched and access was
+	// denied by default.
+	Role      string
+	Rule      *Rule
+	RuleIndex int
+	Reason    string
+}
+
+func (d Decision) String() string {
+	return d.Reason
+}
+
+// EvaluateAccess checks whether the roles allow the action on the resource. Rules with ResourceAny match
+// every resource. Matching deny rules take precedence over allow rules from any role, and access is
+// denied when no rule matches.
+func EvaluateAccess(roles []Role, resource Resource, action Action) Decision {
+	var allow *Decision
+	for _, role := range roles {
+		for i := range role.Config.Rules {
+			rule := &role.Config.Rules[i]
+			if !rule.matches(resource, action) {
+				continue
+			}
+
+			switch rule.Effect {
+			case EffectDeny:
+				return Decision{
+					Allowed:   false,
+					Role:      role.Name,
+					Rule:      rule,
+					RuleIndex: i,
+					Reason:    fmt.Sprintf("%s on %s denied by role '%s' rule %d", action, resource, role.Name, i),
+				}
+			case EffectAllow:
+				if allow == nil {
+					allow = &Decision{
+						Allowed:   true,
+						Role:      role.Name,
+						Rule:      rule,
+						RuleIndex: i,
+						Reason:    fmt.Sprintf("%s on %s allowed by role '%s' rule %d", action, resource, role.Name, i),
+					}
+				}
+			}
+		}
+	}
+
+	if allow != nil {
+		return *allow
+	}
+	return Decision{
+		Allowed: false,
+		Reason:  fmt.Sprintf("%s on %s denied, no rule matched", action, resource),
+	}
+}
+
+func (r *Rule) matches(resource Resource, action Action) bool {
+	var resourceMatched bool
+	for _, res := range r.Resources {
+		if res == ResourceAny || res == resource {
+			resourceMatched = true
+			break
+		}
+	}
+	if !resourceMatched {
+		return false
+	}
+
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// CanI checks whether the caller is allowed to perform the action on the resource in the current project,
+// based on the roles of the user membership or the service account.
+func (api *API) CanI(ctx context.Context, resource Resource, action Action) (Decision, error) {
+	roles, err := api.callerRoles(ctx)
+	if err != nil {
+		return Decision{}, err
+	}
+	return EvaluateAccess(roles, resource, action), nil
+}
+
+// callerRoles returns roles of the caller in the current project.
+func (api *API) callerRoles(ctx context.Context) ([]Role, error) {
+	identity, err := api.WhoAmI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, ok := identity.ProjectRoles(api.ProjectID)
+	if !ok {
+		return nil, fmt.Errorf("no access to project '%s'", api.ProjectID)
+	}
+	return roles, nil
+}
@@ -0,0 +1,95 @@
+package synpse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateAccess(t *testing.T) {
+	viewer := Role{Name: "viewer", Config: Config{Rules: []Rule{
+		{Resources: []Resource{ResourceAny}, Actions: []Action{ActionGet, ActionList}, Effect: EffectAllow},
+	}}}
+	noSecrets := Role{Name: "no-secrets", Config: Config{Rules: []Rule{
+		{Resources: []Resource{ResourceSecret}, Actions: []Action{ActionGet, ActionList}, Effect: EffectDeny},
+	}}}
+	deployer := Role{Name: "deployer", Config: Config{Rules: []Rule{
+		{Resources: []Resource{ResourceNamespace}, Actions: []Action{ActionGet}, Effect: EffectAllow},
+		{Resources: []Resource{ResourceApplication}, Actions: []Action{ActionCreate, ActionUpdate}, Effect: EffectAllow},
+	}}}
+
+	tests := []struct {
+		name     string
+		roles    []Role
+		resource Resource
+		action   Action
+		allowed  bool
+		role     string
+		index    int
+		reason   string
+	}{
+		{
+			name:     "any resource",
+			roles:    []Role{viewer},
+			resource: ResourceDevice,
+			action:   ActionList,
+			allowed:  true,
+			role:     "viewer",
+			reason:   "List on Device allowed by role 'viewer' rule 0",
+		},
+		{
+			name:     "deny overrides allow from another role",
+			roles:    []Role{viewer, noSecrets},
+			resource: ResourceSecret,
+			action:   ActionGet,
+			allowed:  false,
+			role:     "no-secrets",
+			reason:   "Get on Secret denied by role 'no-secrets' rule 0",
+		},
+		{
+			name:     "deny does not affect other resources",
+			roles:    []Role{viewer, noSecrets},
+			resource: ResourceApplication,
+			action:   ActionGet,
+			allowed:  true,
+			role:     "viewer",
+			reason:   "Get on Application allowed by role 'viewer' rule 0",
+		},
+		{
+			name:     "second rule",
+			roles:    []Role{deployer},
+			resource: ResourceApplication,
+			action:   ActionUpdate,
+			allowed:  true,
+			role:     "deployer",
+			index:    1,
+			reason:   "Update on Application allowed by role 'deployer' rule 1",
+		},
+		{
+			name:     "default deny",
+			roles:    []Role{viewer, deployer},
+			resource: ResourceDevice,
+			action:   ActionReboot,
+			allowed:  false,
+			reason:   "Reboot on Device denied, no rule matched",
+		},
+		{
+			name:     "no roles",
+			resource: ResourceProject,
+			action:   ActionGet,
+			allowed:  false,
+			reason:   "Get on Project denied, no rule matched",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := EvaluateAccess(tt.roles, tt.resource, tt.action)
+			assert.Equal(t, tt.allowed, d.Allowed)
+			assert.Equal(t, tt.role, d.Role)
+			assert.Equal(t, tt.index, d.RuleIndex)
+			assert.Equal(t, tt.reason, d.Reason)
+			assert.Equal(t, tt.role != "", d.Rule != nil)
+		})
+	}
+}
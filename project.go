@@ -3,6 +3,7 @@ package synpse
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -27,6 +28,147 @@ func (api *API) CreateProject(ctx context.Context, project Project) (*Project, e
 	return &result, nil
 }
 
+// GetProject returns the project together with its resource counts.
+func (api *API) GetProject(ctx context.Context, projectID string) (*Project, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("project ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, projectID+"?full"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Project
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// UpdateProject updates the project name. Quota is managed by the subscription and cannot be changed.
+func (api *API) UpdateProject(ctx context.Context, project Project) (*Project, error) {
+	if project.ID == "" {
+		return nil, fmt.Errorf("project ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPatch, getURL(api.BaseURL, projectsURL, project.ID), project)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Project
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// DeleteProject deletes the project and all resources within it.
+// Note: this API can only be called with personal access keys (https://cloud.synpse.net/access-keys).
+func (api *API) DeleteProject(ctx context.Context, projectID string) error {
+	if projectID == "" {
+		return fmt.Errorf("project ID not specified")
+	}
+
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, projectID), nil)
+	return err
+}
+
+// ProjectUsage is the current resource usage of the project against its quota.
+type ProjectUsage struct {
+	Quota        ProjectQuota
+	Devices      int
+	Namespaces   int
+	Applications int
+	Secrets      int
+}
+
+// GetProjectUsage returns resource usage of the current project.
+func (api *API) GetProjectUsage(ctx context.Context) (*ProjectUsage, error) {
+	project, err := api.GetProject(ctx, api.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProjectUsage{
+		Quota:        project.Quota,
+		Devices:      project.DeviceCount,
+		Namespaces:   project.NamespaceCount,
+		Applications: project.ApplicationCount,
+		Secrets:      project.SecretCount,
+	}, nil
+}
+
+// Headroom returns how many more resources of the type can be created. Resources without a quota
+// (quota is 0) and resource types that are not limited return -1.
+func (u *ProjectUsage) Headroom(resource Resource) int {
+	var used, limit int
+	switch resource {
+	case ResourceDevice:
+		used, limit = u.Devices, u.Quota.Devices
+	case ResourceNamespace:
+		used, limit = u.Namespaces, u.Quota.Namespaces
+	case ResourceApplication:
+		used, limit = u.Applications, u.Quota.Applications
+	case ResourceSecret:
+		used, limit = u.Secrets, u.Quota.Secrets
+	default:
+		return -1
+	}
+
+	if limit <= 0 {
+		return -1
+	}
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+// Check returns a *QuotaExceededError if creating count more resources of the type would exceed the quota.
+func (u *ProjectUsage) Check(resource Resource, count int) error {
+	headroom := u.Headroom(resource)
+	if headroom < 0 || count <= headroom {
+		return nil
+	}
+	return &QuotaExceededError{
+		Resource:  resource,
+		Requested: count,
+		Available: headroom,
+	}
+}
+
+// CheckProjectQuota checks whether count more resources of the type can be created in the current
+// project. Use it before bulk creates to fail early instead of partially creating resources.
+func (api *API) CheckProjectQuota(ctx context.Context, resource Resource, count int) error {
+	usage, err := api.GetProjectUsage(ctx)
+	if err != nil {
+		return err
+	}
+	return usage.Check(resource, count)
+}
+
+// QuotaExceededError is returned when creating resources would exceed the project quota. It matches
+// ErrQuotaExceeded with errors.Is.
+type QuotaExceededError struct {
+	Resource  Resource
+	Requested int
+	Available int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: requested %d %s resource(s), %d available", ErrQuotaExceeded, e.Requested, e.Resource, e.Available)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
 type ListProjectsRequest struct{}
 
 // ListProjects returns a list of projects that the user has access to.
@@ -51,6 +193,33 @@ func (api *API) ListProjects(ctx context.Context, req *ListProjectsRequest) ([]P
 	return projects, nil
 }
 
+// Project returns a client scoped to the specified project. The returned client shares the HTTP client,
+// rate limiter, retry policy and other options with the parent client, so requests made through any of
+// them count towards the same rate limit. Project is safe to call concurrently and the returned client
+// can be used concurrently with the parent as long as neither of them is modified.
+func (api *API) Project(projectID string) *API {
+	scoped := *api
+	scoped.ProjectID = projectID
+	return &scoped
+}
+
+// ForEachProject calls fn with a project scoped client for each project that the user has access to,
+// stopping at the first error. Like ListProjects, it requires a personal access key.
+func (api *API) ForEachProject(ctx context.Context, fn func(ctx context.Context, client *API, project Project) error) error {
+	projects, err := api.ListProjects(ctx, &ListProjectsRequest{})
+	if err != nil {
+		return err
+	}
+
+	for _, project := range projects {
+		err = fn(ctx, api.Project(project.ID), project)
+		if err != nil {
+			return fmt.Errorf("project '%s': %w", project.Name, err)
+		}
+	}
+	return nil
+}
+
 type Project struct {
 	ID        string    `json:"id" yaml:"id" gorm:"primaryKey"`
 	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
@@ -2,6 +2,7 @@ package synpse
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 
@@ -32,3 +33,45 @@ func TestListProjects(t *testing.T) {
 
 	assert.True(t, projectFound, "expected to find testing project, check SYNPSE_SDK_TEST_PROJECT_ID and SYNPSE_SDK_TEST_PROJECT_NAME env vars")
 }
+
+func TestProjectScopedClient(t *testing.T) {
+	client, err := NewWithProject("key", "prj_parent")
+	require.NoError(t, err)
+
+	scoped := client.Project("prj_other")
+	assert.Equal(t, "prj_other", scoped.ProjectID)
+	assert.Equal(t, "prj_parent", client.ProjectID, "parent project must not change")
+
+	assert.Same(t, client.httpClient, scoped.httpClient)
+	assert.Same(t, client.rateLimiter, scoped.rateLimiter)
+	assert.Equal(t, client.retryPolicy, scoped.retryPolicy)
+	assert.Equal(t, client.APIAccessKey, scoped.APIAccessKey)
+}
+
+func TestProjectUsage(t *testing.T) {
+	usage := &ProjectUsage{
+		Quota:        ProjectQuota{Devices: 5, Applications: 10, Secrets: 3},
+		Devices:      4,
+		Applications: 10,
+		Secrets:      1,
+		Namespaces:   7,
+	}
+
+	assert.Equal(t, 1, usage.Headroom(ResourceDevice))
+	assert.Equal(t, 0, usage.Headroom(ResourceApplication))
+	assert.Equal(t, 2, usage.Headroom(ResourceSecret))
+	assert.Equal(t, -1, usage.Headroom(ResourceNamespace), "namespaces have no quota")
+	assert.Equal(t, -1, usage.Headroom(ResourceRole))
+
+	assert.NoError(t, usage.Check(ResourceDevice, 1))
+	assert.NoError(t, usage.Check(ResourceNamespace, 100))
+
+	err := usage.Check(ResourceSecret, 3)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+
+	var quotaErr *QuotaExceededError
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, ResourceSecret, quotaErr.Resource)
+	assert.Equal(t, 2, quotaErr.Available)
+}
@@ -0,0 +1,128 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ListRoles returns roles defined in the current project.
+func (api *API) ListRoles(ctx context.Context) ([]*Role, error) {
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, rolesURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Role
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return result, nil
+}
+
+// GetRole returns role by ID.
+func (api *API) GetRole(ctx context.Context, roleID string) (*Role, error) {
+	if roleID == "" {
+		return nil, fmt.Errorf("role ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, rolesURL, roleID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Role
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// CreateRole creates a new role in the current project.
+func (api *API) CreateRole(ctx context.Context, role Role) (*Role, error) {
+	err := role.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, rolesURL), role)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Role
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// UpdateRole replaces role name, description and rules.
+func (api *API) UpdateRole(ctx context.Context, role Role) (*Role, error) {
+	if role.ID == "" {
+		return nil, fmt.Errorf("role ID not specified")
+	}
+	err := role.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPut, getURL(api.BaseURL, projectsURL, api.ProjectID, rolesURL, role.ID), role)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Role
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// DeleteRole deletes the role. Memberships and service accounts bound to it lose the permissions it granted.
+func (api *API) DeleteRole(ctx context.Context, roleID string) error {
+	if roleID == "" {
+		return fmt.Errorf("role ID not specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, api.ProjectID, rolesURL, roleID), nil)
+	return err
+}
+
+// Validate checks that the role has a name and that every rule has resources, actions and a known effect.
+func (r *Role) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("role name not specified")
+	}
+	for i, rule := range r.Config.Rules {
+		err := rule.Validate()
+		if err != nil {
+			return fmt.Errorf("role '%s' rule %d: %w", r.Name, i, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the rule has resources, actions and a known effect.
+func (r Rule) Validate() error {
+	if len(r.Resources) == 0 {
+		return fmt.Errorf("no resources specified")
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("no actions specified")
+	}
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("unknown effect '%s', expected '%s' or '%s'", r.Effect, EffectAllow, EffectDeny)
+	}
+	return nil
+}
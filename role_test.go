@@ -0,0 +1,54 @@
+package synpse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    Role
+		wantErr string
+	}{
+		{
+			name: "valid",
+			role: Role{Name: "viewer", Config: Config{Rules: []Rule{
+				{Resources: []Resource{ResourceAny}, Actions: []Action{ActionGet, ActionList}, Effect: EffectAllow},
+				{Resources: []Resource{ResourceSecret}, Actions: []Action{ActionGet}, Effect: EffectDeny},
+			}}},
+		},
+		{
+			name:    "no name",
+			role:    Role{},
+			wantErr: "role name not specified",
+		},
+		{
+			name:    "no resources",
+			role:    Role{Name: "r", Config: Config{Rules: []Rule{{Actions: []Action{ActionGet}, Effect: EffectAllow}}}},
+			wantErr: "role 'r' rule 0: no resources specified",
+		},
+		{
+			name:    "no actions",
+			role:    Role{Name: "r", Config: Config{Rules: []Rule{{Resources: []Resource{ResourceDevice}, Effect: EffectAllow}}}},
+			wantErr: "role 'r' rule 0: no actions specified",
+		},
+		{
+			name:    "unknown effect",
+			role:    Role{Name: "r", Config: Config{Rules: []Rule{{Resources: []Resource{ResourceDevice}, Actions: []Action{ActionGet}, Effect: "maybe"}}}},
+			wantErr: "role 'r' rule 0: unknown effect 'maybe', expected 'allow' or 'deny'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.role.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package synpse
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRolloutLabel is the device label used to split devices between the stable and the
+	// canary application during a rollout.
+	DefaultRolloutLabel = "synpse-rollout"
+
+	rolloutLabelStable = "stable"
+	rolloutLabelCanary = "canary"
+
+	canaryApplicationSuffix = "-canary"
+)
+
+// RolloutConfig configures a staged application rollout.
+type RolloutConfig struct {
+	// Steps is the percentage of targeted devices running the new application after each step. Defaults
+	// to 10, 50, 100. The last step is always widened to 100.
+	Steps []int
+	// FailureThreshold is the fraction (0-1) of unavailable or unhealthy canary deployments that is
+	// tolerated when a step times out. Anything above it reverts the rollout. Defaults to 0.
+	FailureThreshold float64
+	// StepTimeout is how long to wait for the canary deployments in a step to become available.
+	// Defaults to 10 minutes.
+	StepTimeout time.Duration
+	// PollInterval is how often the canary application deployment status is checked. Defaults to 10 seconds.
+	PollInterval time.Duration
+	// Label is the device label key used to split devices. Defaults to DefaultRolloutLabel.
+	Label string
+	// OnProgress is called after each status check, optional.
+	OnProgress func(RolloutStatus)
+}
+
+func (c *RolloutConfig) setDefaults() {
+	if len(c.Steps) == 0 {
+		c.Steps = []int{10, 50, 100}
+	}
+	if c.StepTimeout == 0 {
+		c.StepTimeout = 10 * time.Minute
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = 10 * time.Second
+	}
+	if c.Label == "" {
+		c.Label = DefaultRolloutLabel
+	}
+}
+
+// RolloutStatus reports rollout progress.
+type RolloutStatus struct {
+	Step             int                         // Current step, starting from 1
+	Steps            int                         // Total number of steps
+	CanaryDevices    int                         // Devices moved to the canary application
+	TotalDevices     int                         // Devices targeted by the application
+	DeploymentStatus ApplicationDeploymentStatus // Canary application deployment status
+}
+
+// RolloutApplication updates the application in stages instead of redeploying it on all devices at once.
+//
+// Targeted devices are labeled and the existing application is narrowed to the devices labeled as stable.
+// A copy of the new application, named "<name>-canary", is created for the devices labeled as canary. Each
+// step moves more devices to the canary application and waits for its deployments to become available.
+// Once all steps succeed, the existing application is updated with the new spec and the canary application
+// is deleted before the original scheduling is restored, so devices briefly run neither application. If a
+// step fails, the existing application is left with its previous spec. In both cases, the canary
+// application and the labels are removed and the original scheduling is restored.
+//
+// The rollout keeps the scheduling of the existing application. The new application must either leave
+// Scheduling empty or use the same scheduling, otherwise the rollout is rejected.
+func (api *API) RolloutApplication(ctx context.Context, namespace string, application Application, cfg RolloutConfig) (*Application, error) {
+	if namespace == "" {
+		return nil, ErrNamespaceNotSpecified
+	}
+
+	if err := application.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfg.setDefaults()
+
+	stable, err := api.GetApplication(ctx, namespace, application.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(application.Scheduling, Scheduling{}) && !reflect.DeepEqual(application.Scheduling, stable.Scheduling) {
+		return nil, fmt.Errorf("application '%s' scheduling cannot be changed during a rollout, update it separately", application.Name)
+	}
+
+	devices, err := api.schedulingDevices(ctx, stable.Scheduling)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list application devices: %w", err)
+	}
+
+	if len(devices) == 0 {
+		// Nothing to stage
+		application.ID = stable.ID
+		application.Scheduling = stable.Scheduling
+		return api.UpdateApplication(ctx, namespace, application)
+	}
+
+	originalScheduling := stable.Scheduling
+	sizes := rolloutStepSizes(len(devices), cfg.Steps)
+
+	for _, device := range devices {
+		err = api.setDeviceLabel(ctx, device, cfg.Label, rolloutLabelStable)
+		if err != nil {
+			return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, nil, devices, cfg.Label, err)
+		}
+	}
+
+	narrowed := *stable
+	narrowed.Scheduling = rolloutScheduling(originalScheduling, cfg.Label, rolloutLabelStable)
+	_, err = api.UpdateApplication(ctx, namespace, narrowed)
+	if err != nil {
+		return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, nil, devices, cfg.Label, err)
+	}
+
+	canary := application
+	canary.ID = ""
+	canary.Name = application.Name + canaryApplicationSuffix
+	canary.Scheduling = rolloutScheduling(originalScheduling, cfg.Label, rolloutLabelCanary)
+
+	canaryApp, err := api.CreateApplication(ctx, namespace, canary)
+	if err != nil {
+		return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, nil, devices, cfg.Label, err)
+	}
+
+	moved := 0
+	for i, size := range sizes {
+		for ; moved < size; moved++ {
+			err = api.setDeviceLabel(ctx, devices[moved], cfg.Label, rolloutLabelCanary)
+			if err != nil {
+				return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, canaryApp, devices, cfg.Label, err)
+			}
+		}
+
+		status := RolloutStatus{
+			Step:          i + 1,
+			Steps:         len(sizes),
+			CanaryDevices: size,
+			TotalDevices:  len(devices),
+		}
+		err = api.waitForCanary(ctx, namespace, canaryApp.Name, status, cfg)
+		if err != nil {
+			return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, canaryApp, devices, cfg.Label, err)
+		}
+	}
+
+	// All devices are running the canary, promote it. The stable application gets the new spec while it is
+	// still narrowed to stable devices, so that it doesn't run next to the canary and clash on host ports or
+	// devices. Its original scheduling is restored only after the canary is deleted.
+	application.ID = stable.ID
+	application.Scheduling = narrowed.Scheduling
+	_, err = api.UpdateApplication(ctx, namespace, application)
+	if err != nil {
+		return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, canaryApp, devices, cfg.Label, fmt.Errorf("failed to promote canary application: %w", err))
+	}
+
+	err = api.DeleteApplication(ctx, namespace, canaryApp.Name)
+	if err != nil {
+		return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, canaryApp, devices, cfg.Label, fmt.Errorf("failed to delete canary application: %w", err))
+	}
+
+	application.Scheduling = originalScheduling
+	updated, err := api.UpdateApplication(ctx, namespace, application)
+	if err != nil {
+		return nil, api.revertRollout(ctx, namespace, stable, originalScheduling, nil, devices, cfg.Label, fmt.Errorf("failed to restore application scheduling: %w", err))
+	}
+
+	var failures []string
+	for _, device := range devices {
+		err = api.removeDeviceLabel(ctx, device, cfg.Label)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("device '%s': %v", device.Name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return updated, fmt.Errorf("rollout completed but failed to remove rollout labels: %s", strings.Join(failures, "; "))
+	}
+
+	return updated, nil
+}
+
+// waitForCanary waits until all canary deployments in the step are available. It fails early if
+// the unhealthy deployments exceed the failure threshold or the canary application can't be read,
+// other errors getting the application are retried until the step times out.
+func (api *API) waitForCanary(parent context.Context, namespace, name string, status RolloutStatus, cfg RolloutConfig) error {
+	ctx, cancel := context.WithTimeout(parent, cfg.StepTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		app, err := api.GetApplication(ctx, namespace, name)
+		if err != nil && isPermanentError(err) {
+			return fmt.Errorf("step %d: failed to get canary application: %w", status.Step, err)
+		}
+		if err == nil {
+			status.DeploymentStatus = app.DeploymentStatus
+			if cfg.OnProgress != nil {
+				cfg.OnProgress(status)
+			}
+
+			done, failed := evaluateCanary(app.DeploymentStatus, status.CanaryDevices, cfg.FailureThreshold, false)
+			if failed {
+				return fmt.Errorf("%w: step %d: %d of %d canary deployments unhealthy", ErrRolloutFailed, status.Step, app.DeploymentStatus.Unhealthy, status.CanaryDevices)
+			}
+			if done {
+				return nil
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return err
+			}
+			if done, failed := evaluateCanary(status.DeploymentStatus, status.CanaryDevices, cfg.FailureThreshold, true); done && !failed {
+				return nil
+			}
+			return fmt.Errorf("%w: step %d: %d of %d canary deployments available after %s", ErrRolloutFailed, status.Step, status.DeploymentStatus.Available, status.CanaryDevices, cfg.StepTimeout)
+		}
+	}
+}
+
+// revertRollout restores the stable application spec and scheduling, deletes the canary application and
+// removes rollout labels. Every step is attempted even if an earlier one fails. The returned error wraps
+// the cause of the revert and lists the steps that failed.
+func (api *API) revertRollout(ctx context.Context, namespace string, stable *Application, scheduling Scheduling, canary *Application, devices []*Device, label string, cause error) error {
+	var failures []string
+
+	if stable != nil {
+		restored := *stable
+		restored.Scheduling = scheduling
+		_, err := api.UpdateApplication(ctx, namespace, restored)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to restore application: %v", err))
+		}
+	}
+
+	if canary != nil {
+		err := api.DeleteApplication(ctx, namespace, canary.Name)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			failures = append(failures, fmt.Sprintf("failed to delete canary application: %v", err))
+		}
+	}
+
+	for _, device := range devices {
+		err := api.removeDeviceLabel(ctx, device, label)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to remove rollout label from device '%s': %v", device.Name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("rollout revert incomplete (%s): %w", strings.Join(failures, "; "), cause)
+	}
+	return fmt.Errorf("rollout reverted: %w", cause)
+}
+
+func (api *API) setDeviceLabel(ctx context.Context, device *Device, key, value string) error {
+	updated, err := api.SetDeviceLabels(ctx, device.ID, map[string]string{key: value})
+	if err != nil {
+		return err
+	}
+	*device = *updated
+	return nil
+}
+
+func (api *API) removeDeviceLabel(ctx context.Context, device *Device, key string) error {
+	if _, ok := device.Labels[key]; !ok {
+		return nil
+	}
+
+	updated, err := api.RemoveDeviceLabels(ctx, device.ID, key)
+	if err != nil {
+		return err
+	}
+	*device = *updated
+	return nil
+}
+
+// rolloutScheduling narrows the scheduling to devices with the rollout label set to value.
+func rolloutScheduling(scheduling Scheduling, label, value string) Scheduling {
+	narrowed := Scheduling{
+		Type:      ScheduleTypeConditional,
+		Selectors: make(map[string]string, len(scheduling.Selectors)+1),
+	}
+	if scheduling.Type != ScheduleTypeAllDevices {
+		for k, v := range scheduling.Selectors {
+			narrowed.Selectors[k] = v
+		}
+		narrowed.MatchExpressions = scheduling.MatchExpressions
+		narrowed.MatchFields = scheduling.MatchFields
+	}
+	narrowed.Selectors[label] = value
+
+	return narrowed
+}
+
+// rolloutStepSizes converts step percentages into cumulative device counts. Each step moves
+// at least one device and the last step always covers all devices.
+func rolloutStepSizes(total int, steps []int) []int {
+	var sizes []int
+	for _, pct := range steps {
+		size := int(math.Ceil(float64(total) * float64(pct) / 100))
+		if size > total {
+			size = total
+		}
+		if len(sizes) > 0 && size <= sizes[len(sizes)-1] {
+			continue
+		}
+		if size == 0 {
+			continue
+		}
+		sizes = append(sizes, size)
+	}
+	if len(sizes) == 0 || sizes[len(sizes)-1] < total {
+		sizes = append(sizes, total)
+	}
+	return sizes
+}
+
+// evaluateCanary checks the canary deployment status against the expected number of deployments. Failures
+// are deployments that are not available, and the step fails once they exceed the threshold. Unhealthy
+// deployments fail the step early, pending deployments only count as failures once the step has timed out.
+func evaluateCanary(status ApplicationDeploymentStatus, expected int, threshold float64, timedOut bool) (done, failed bool) {
+	if expected == 0 {
+		return true, false
+	}
+
+	if float64(status.Unhealthy)/float64(expected) > threshold {
+		return true, true
+	}
+
+	failures := expected - status.Available
+	if failures < 0 {
+		failures = 0
+	}
+
+	// Every deployment is either available or unhealthy, or there is nothing left to wait for
+	if status.Available+status.Unhealthy >= expected || timedOut {
+		return true, float64(failures)/float64(expected) > threshold
+	}
+
+	return false, false
+}
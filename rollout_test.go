@@ -0,0 +1,84 @@
+package synpse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutStepSizes(t *testing.T) {
+	assert.Equal(t, []int{1, 5, 10}, rolloutStepSizes(10, []int{10, 50, 100}))
+	assert.Equal(t, []int{1, 2, 3}, rolloutStepSizes(3, []int{10, 50, 100}))
+	assert.Equal(t, []int{1}, rolloutStepSizes(1, []int{10, 50, 100}))
+	assert.Equal(t, []int{2, 20}, rolloutStepSizes(20, []int{10}))
+	assert.Equal(t, []int{20}, rolloutStepSizes(20, []int{0}))
+}
+
+func TestEvaluateCanary(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     ApplicationDeploymentStatus
+		threshold  float64
+		timedOut   bool
+		wantDone   bool
+		wantFailed bool
+	}{
+		{"Pending", ApplicationDeploymentStatus{Pending: 4, Total: 4}, 0, false, false, false},
+		{"Available", ApplicationDeploymentStatus{Available: 4, Total: 4}, 0, false, true, false},
+		{"Unhealthy", ApplicationDeploymentStatus{Available: 4, Unhealthy: 1, Total: 4}, 0, false, true, true},
+		{"UnhealthyWithinThreshold", ApplicationDeploymentStatus{Available: 3, Unhealthy: 1, Total: 4}, 0.25, false, true, false},
+		{"UnhealthyWithinThresholdPending", ApplicationDeploymentStatus{Available: 2, Unhealthy: 1, Pending: 1, Total: 4}, 0.25, false, false, false},
+		{"TimedOut", ApplicationDeploymentStatus{Available: 3, Pending: 1, Total: 4}, 0, true, true, true},
+		{"TimedOutWithinThreshold", ApplicationDeploymentStatus{Available: 3, Pending: 1, Total: 4}, 0.25, true, true, false},
+		{"TimedOutUnhealthyAtThreshold", ApplicationDeploymentStatus{Available: 3, Unhealthy: 1, Total: 4}, 0.25, true, true, false},
+		{"TimedOutUnhealthyAndPendingAtThreshold", ApplicationDeploymentStatus{Available: 2, Unhealthy: 1, Pending: 1, Total: 4}, 0.5, true, true, false},
+		{"TimedOutUnhealthyAndPendingOverThreshold", ApplicationDeploymentStatus{Available: 2, Unhealthy: 1, Pending: 1, Total: 4}, 0.25, true, true, true},
+		{"UnhealthyOverThreshold", ApplicationDeploymentStatus{Available: 2, Unhealthy: 2, Total: 4}, 0.25, false, true, true},
+		{"UnhealthyAtThresholdDone", ApplicationDeploymentStatus{Available: 2, Unhealthy: 2, Total: 4}, 0.5, false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, failed := evaluateCanary(tt.status, 4, tt.threshold, tt.timedOut)
+			assert.Equal(t, tt.wantDone, done, "done")
+			assert.Equal(t, tt.wantFailed, failed, "failed")
+		})
+	}
+}
+
+func TestRolloutScheduling(t *testing.T) {
+	conditional := Scheduling{Type: ScheduleTypeConditional, Selectors: map[string]string{"site": "riga"}}
+	assert.Equal(t, Scheduling{
+		Type:      ScheduleTypeConditional,
+		Selectors: map[string]string{"site": "riga", DefaultRolloutLabel: rolloutLabelCanary},
+	}, rolloutScheduling(conditional, DefaultRolloutLabel, rolloutLabelCanary))
+	assert.Len(t, conditional.Selectors, 1, "original selectors must not be modified")
+
+	all := Scheduling{Type: ScheduleTypeAllDevices}
+	assert.Equal(t, Scheduling{
+		Type:      ScheduleTypeConditional,
+		Selectors: map[string]string{DefaultRolloutLabel: rolloutLabelStable},
+	}, rolloutScheduling(all, DefaultRolloutLabel, rolloutLabelStable))
+}
+
+func TestWaitForCanaryPermanentError(t *testing.T) {
+	requests := 0
+	client := getFakeServerClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	status := RolloutStatus{Step: 1, Steps: 1, CanaryDevices: 1, TotalDevices: 1}
+	err := client.waitForCanary(context.Background(), "default", "app-canary", status, RolloutConfig{
+		StepTimeout:  time.Minute,
+		PollInterval: 10 * time.Millisecond,
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrForbidden), "expected a permissions error, got: %v", err)
+	assert.Equal(t, 1, requests)
+}
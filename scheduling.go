@@ -0,0 +1,293 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type Scheduling struct {
+	Type      ScheduleType      `json:"type" yaml:"type"`
+	Selectors map[string]string `json:"selectors" yaml:"selectors"` // Exact label matches
+
+	// MatchExpressions are set-based label requirements, all of them must match
+	MatchExpressions []SelectorExpression `json:"matchExpressions,omitempty" yaml:"matchExpressions,omitempty"`
+	// MatchFields are requirements on device attributes such as architecture, all of them must match.
+	// Keys are one of the DeviceField values.
+	MatchFields []SelectorExpression `json:"matchFields,omitempty" yaml:"matchFields,omitempty"`
+}
+
+type ScheduleType string
+
+const (
+	ScheduleTypeNoDevices   ScheduleType = "NoDevices" // Optional, defaults when no type and no selectors are specified
+	ScheduleTypeAllDevices  ScheduleType = "AllDevices"
+	ScheduleTypeConditional ScheduleType = "Conditional" // Optional, defaults when no type but selectors are specified
+)
+
+// ScheduleTypes returns all known schedule types.
+func ScheduleTypes() []ScheduleType {
+	return []ScheduleType{ScheduleTypeNoDevices, ScheduleTypeAllDevices, ScheduleTypeConditional}
+}
+
+// Valid returns true for known schedule types and the empty type, which is inferred from the
+// selectors (see Scheduling.Effective).
+func (t ScheduleType) Valid() bool {
+	switch t {
+	case "", ScheduleTypeNoDevices, ScheduleTypeAllDevices, ScheduleTypeConditional:
+		return true
+	}
+	return false
+}
+
+func (t *ScheduleType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return t.set(s)
+}
+
+func (t *ScheduleType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return t.set(s)
+}
+
+func (t *ScheduleType) set(s string) error {
+	if !ScheduleType(s).Valid() {
+		return fmt.Errorf("unknown schedule type '%s', expected one of %v", s, ScheduleTypes())
+	}
+	*t = ScheduleType(s)
+	return nil
+}
+
+// Effective returns the scheduling with the type inferred when it's not set: Conditional when
+// selectors or expressions are set, NoDevices otherwise.
+func (s Scheduling) Effective() Scheduling {
+	if s.Type != "" {
+		return s
+	}
+	if s.hasRequirements() {
+		s.Type = ScheduleTypeConditional
+	} else {
+		s.Type = ScheduleTypeNoDevices
+	}
+	return s
+}
+
+// SelectorExpression is a requirement on a device label or a device field, e.g.
+// {Key: "site", Operator: SelectorOpIn, Values: ["riga", "tallinn"]}.
+type SelectorExpression struct {
+	Key      string           `json:"key" yaml:"key"`
+	Operator SelectorOperator `json:"operator" yaml:"operator"`
+	Values   []string         `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+type SelectorOperator string
+
+const (
+	SelectorOpIn           SelectorOperator = "In"
+	SelectorOpNotIn        SelectorOperator = "NotIn"
+	SelectorOpExists       SelectorOperator = "Exists"       // Label is set or field is not empty
+	SelectorOpDoesNotExist SelectorOperator = "DoesNotExist" // Label is not set or field is empty
+)
+
+// DeviceField is a device attribute that can be used in Scheduling.MatchFields.
+type DeviceField string
+
+const (
+	DeviceFieldID              DeviceField = "id"
+	DeviceFieldName            DeviceField = "name"
+	DeviceFieldArchitecture    DeviceField = "info.architecture"
+	DeviceFieldHostname        DeviceField = "info.hostname"
+	DeviceFieldAgentVersion    DeviceField = "info.agentVersion"
+	DeviceFieldOSReleaseID     DeviceField = "info.osRelease.id"
+	DeviceFieldOSReleaseIDLike DeviceField = "info.osRelease.idLike"
+	DeviceFieldOSVersionID     DeviceField = "info.osRelease.versionId"
+	DeviceFieldDockerVersion   DeviceField = "info.docker.version"
+	DeviceFieldDockerOSType    DeviceField = "info.docker.osType"
+)
+
+var deviceFields = map[DeviceField]func(d *Device) string{
+	DeviceFieldID:              func(d *Device) string { return d.ID },
+	DeviceFieldName:            func(d *Device) string { return d.Name },
+	DeviceFieldArchitecture:    func(d *Device) string { return d.Info.Architecture },
+	DeviceFieldHostname:        func(d *Device) string { return d.Info.Hostname },
+	DeviceFieldAgentVersion:    func(d *Device) string { return d.Info.AgentVersion },
+	DeviceFieldOSReleaseID:     func(d *Device) string { return d.Info.OSRelease.ID },
+	DeviceFieldOSReleaseIDLike: func(d *Device) string { return d.Info.OSRelease.IDLike },
+	DeviceFieldOSVersionID:     func(d *Device) string { return d.Info.OSRelease.VersionID },
+	DeviceFieldDockerVersion:   func(d *Device) string { return d.Info.Docker.Version },
+	DeviceFieldDockerOSType:    func(d *Device) string { return d.Info.Docker.OSType },
+}
+
+// Validate checks the schedule type and selector expression operators, values and field keys.
+func (s Scheduling) Validate() error {
+	if !s.Type.Valid() {
+		return fmt.Errorf("unknown schedule type '%s'", s.Type)
+	}
+	for _, expr := range s.MatchExpressions {
+		if err := expr.validate(); err != nil {
+			return fmt.Errorf("invalid match expression: %w", err)
+		}
+	}
+	for _, expr := range s.MatchFields {
+		if _, ok := deviceFields[DeviceField(expr.Key)]; !ok {
+			return fmt.Errorf("invalid match field: unknown device field '%s'", expr.Key)
+		}
+		if err := expr.validate(); err != nil {
+			return fmt.Errorf("invalid match field: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e SelectorExpression) validate() error {
+	if e.Key == "" {
+		return fmt.Errorf("key not specified")
+	}
+	switch e.Operator {
+	case SelectorOpIn, SelectorOpNotIn:
+		if len(e.Values) == 0 {
+			return fmt.Errorf("'%s' operator on '%s' requires values", e.Operator, e.Key)
+		}
+	case SelectorOpExists, SelectorOpDoesNotExist:
+		if len(e.Values) > 0 {
+			return fmt.Errorf("'%s' operator on '%s' doesn't take values", e.Operator, e.Key)
+		}
+	default:
+		return fmt.Errorf("unknown operator '%s' on '%s'", e.Operator, e.Key)
+	}
+	return nil
+}
+
+// matches evaluates the expression against a value and whether the value is set.
+func (e SelectorExpression) matches(value string, exists bool) bool {
+	switch e.Operator {
+	case SelectorOpIn:
+		return exists && containsString(e.Values, value)
+	case SelectorOpNotIn:
+		return !exists || !containsString(e.Values, value)
+	case SelectorOpExists:
+		return exists
+	case SelectorOpDoesNotExist:
+		return !exists
+	default:
+		return false
+	}
+}
+
+// hasRequirements returns true if any selectors, label expressions or field expressions are set.
+func (s Scheduling) hasRequirements() bool {
+	return len(s.Selectors) > 0 || len(s.MatchExpressions) > 0 || len(s.MatchFields) > 0
+}
+
+// Matches reports whether the scheduling targets the device. Scheduling without a type is evaluated as
+// its effective type (see Effective). Conditional scheduling without selectors and expressions targets
+// no devices.
+func (s Scheduling) Matches(device *Device) bool {
+	switch s.Effective().Type {
+	case ScheduleTypeAllDevices:
+		return true
+	case ScheduleTypeConditional:
+		if !s.hasRequirements() {
+			return false
+		}
+		for k, v := range s.Selectors {
+			if label, ok := device.Labels[k]; !ok || label != v {
+				return false
+			}
+		}
+		for _, expr := range s.MatchExpressions {
+			label, ok := device.Labels[expr.Key]
+			if !expr.matches(label, ok) {
+				return false
+			}
+		}
+		for _, expr := range s.MatchFields {
+			field, ok := deviceFields[DeviceField(expr.Key)]
+			if !ok {
+				return false
+			}
+			value := field(device)
+			if !expr.matches(value, value != "") {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PreviewSchedulingResponse lists devices that an application or a job with the scheduling would be
+// deployed on.
+type PreviewSchedulingResponse struct {
+	Devices []*Device
+	Online  int
+	Offline int
+}
+
+// PreviewScheduling evaluates the scheduling against project devices without creating any workloads.
+// Use it to check the target devices before creating an application or a job.
+func (api *API) PreviewScheduling(ctx context.Context, scheduling Scheduling) (*PreviewSchedulingResponse, error) {
+	devices, err := api.schedulingDevices(ctx, scheduling)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PreviewSchedulingResponse{Devices: devices}
+	for _, device := range devices {
+		if device.Status == DeviceStatusOnline {
+			result.Online++
+		} else {
+			result.Offline++
+		}
+	}
+
+	return result, nil
+}
+
+// schedulingDevices lists devices that the scheduling targets.
+func (api *API) schedulingDevices(ctx context.Context, scheduling Scheduling) ([]*Device, error) {
+	if err := scheduling.Validate(); err != nil {
+		return nil, err
+	}
+
+	var labels map[string]string
+
+	scheduling = scheduling.Effective()
+	switch {
+	case scheduling.Type == ScheduleTypeAllDevices:
+	case scheduling.Type == ScheduleTypeConditional && scheduling.hasRequirements():
+		// Narrow down the list on the server side, selectors are still evaluated locally
+		labels = scheduling.Selectors
+	default:
+		return nil, nil
+	}
+
+	devices, err := api.listAllDevices(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*Device
+	for _, device := range devices {
+		if scheduling.Matches(device) {
+			matched = append(matched, device)
+		}
+	}
+	return matched, nil
+}
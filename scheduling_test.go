@@ -0,0 +1,128 @@
+package synpse
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSchedulingMatches(t *testing.T) {
+	device := &Device{
+		Name:   "gateway-1",
+		Labels: map[string]string{"site": "riga", "type": "gateway"},
+		Info: DeviceInfo{
+			Architecture: "arm64",
+			OSRelease:    OSRelease{ID: "debian"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		scheduling Scheduling
+		want       bool
+	}{
+		{"Empty", Scheduling{}, false},
+		{"NoDevices", Scheduling{Type: ScheduleTypeNoDevices, Selectors: map[string]string{"site": "riga"}}, false},
+		{"AllDevices", Scheduling{Type: ScheduleTypeAllDevices}, true},
+		{"ConditionalMatch", Scheduling{Type: ScheduleTypeConditional, Selectors: map[string]string{"site": "riga", "type": "gateway"}}, true},
+		{"ConditionalMismatch", Scheduling{Type: ScheduleTypeConditional, Selectors: map[string]string{"site": "tallinn"}}, false},
+		{"ConditionalMissingLabel", Scheduling{Type: ScheduleTypeConditional, Selectors: map[string]string{"zone": "a"}}, false},
+		{"ConditionalWithoutSelectors", Scheduling{Type: ScheduleTypeConditional}, false},
+		{"InferredConditional", Scheduling{Selectors: map[string]string{"site": "riga"}}, true},
+		{"In", Scheduling{MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpIn, Values: []string{"riga", "tallinn"}}}}, true},
+		{"InMissingLabel", Scheduling{MatchExpressions: []SelectorExpression{{Key: "zone", Operator: SelectorOpIn, Values: []string{"a"}}}}, false},
+		{"NotIn", Scheduling{MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpNotIn, Values: []string{"riga"}}}}, false},
+		{"NotInMissingLabel", Scheduling{MatchExpressions: []SelectorExpression{{Key: "zone", Operator: SelectorOpNotIn, Values: []string{"a"}}}}, true},
+		{"Exists", Scheduling{MatchExpressions: []SelectorExpression{{Key: "type", Operator: SelectorOpExists}}}, true},
+		{"DoesNotExist", Scheduling{MatchExpressions: []SelectorExpression{{Key: "type", Operator: SelectorOpDoesNotExist}}}, false},
+		{"SelectorsAndExpressions", Scheduling{
+			Type:             ScheduleTypeConditional,
+			Selectors:        map[string]string{"type": "gateway"},
+			MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpNotIn, Values: []string{"tallinn"}}},
+		}, true},
+		{"FieldArchitecture", Scheduling{MatchFields: []SelectorExpression{{Key: string(DeviceFieldArchitecture), Operator: SelectorOpIn, Values: []string{"arm64"}}}}, true},
+		{"FieldArchitectureMismatch", Scheduling{MatchFields: []SelectorExpression{{Key: string(DeviceFieldArchitecture), Operator: SelectorOpIn, Values: []string{"amd64"}}}}, false},
+		{"FieldOSReleaseNotIn", Scheduling{MatchFields: []SelectorExpression{{Key: string(DeviceFieldOSReleaseID), Operator: SelectorOpNotIn, Values: []string{"ubuntu"}}}}, true},
+		{"FieldEmpty", Scheduling{MatchFields: []SelectorExpression{{Key: string(DeviceFieldDockerVersion), Operator: SelectorOpDoesNotExist}}}, true},
+		{"UnknownField", Scheduling{MatchFields: []SelectorExpression{{Key: "info.unknown", Operator: SelectorOpExists}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.scheduling.Matches(device))
+		})
+	}
+}
+
+func TestSchedulingValidate(t *testing.T) {
+	assert.NoError(t, Scheduling{
+		MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpIn, Values: []string{"riga"}}},
+		MatchFields:      []SelectorExpression{{Key: string(DeviceFieldArchitecture), Operator: SelectorOpExists}},
+	}.Validate())
+
+	assert.Error(t, Scheduling{MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpIn}}}.Validate())
+	assert.Error(t, Scheduling{MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpExists, Values: []string{"riga"}}}}.Validate())
+	assert.Error(t, Scheduling{MatchExpressions: []SelectorExpression{{Key: "site", Operator: "Equals", Values: []string{"riga"}}}}.Validate())
+	assert.Error(t, Scheduling{MatchFields: []SelectorExpression{{Key: "info.unknown", Operator: SelectorOpExists}}}.Validate())
+}
+
+func TestSchedulingEncoding(t *testing.T) {
+	scheduling := Scheduling{
+		Type:             ScheduleTypeConditional,
+		Selectors:        map[string]string{"type": "gateway"},
+		MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpNotIn, Values: []string{"tallinn"}}},
+		MatchFields:      []SelectorExpression{{Key: string(DeviceFieldArchitecture), Operator: SelectorOpIn, Values: []string{"arm64"}}},
+	}
+
+	bts, err := json.Marshal(scheduling)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "Conditional",
+		"selectors": {"type": "gateway"},
+		"matchExpressions": [{"key": "site", "operator": "NotIn", "values": ["tallinn"]}],
+		"matchFields": [{"key": "info.architecture", "operator": "In", "values": ["arm64"]}]
+	}`, string(bts))
+
+	var decoded Scheduling
+	require.NoError(t, json.Unmarshal(bts, &decoded))
+	assert.Equal(t, scheduling, decoded)
+
+	bts, err = yaml.Marshal(scheduling)
+	require.NoError(t, err)
+
+	decoded = Scheduling{}
+	require.NoError(t, yaml.Unmarshal(bts, &decoded))
+	assert.Equal(t, scheduling, decoded)
+}
+
+func TestSchedulingEffective(t *testing.T) {
+	assert.Equal(t, ScheduleTypeNoDevices, Scheduling{}.Effective().Type)
+	assert.Equal(t, ScheduleTypeConditional, Scheduling{Selectors: map[string]string{"site": "riga"}}.Effective().Type)
+	assert.Equal(t, ScheduleTypeConditional, Scheduling{MatchExpressions: []SelectorExpression{{Key: "site", Operator: SelectorOpExists}}}.Effective().Type)
+	assert.Equal(t, ScheduleTypeAllDevices, Scheduling{Type: ScheduleTypeAllDevices, Selectors: map[string]string{"site": "riga"}}.Effective().Type)
+}
+
+func TestEnumDecoding(t *testing.T) {
+	var scheduling Scheduling
+	assert.NoError(t, json.Unmarshal([]byte(`{"type": "AllDevices"}`), &scheduling))
+	assert.Equal(t, ScheduleTypeAllDevices, scheduling.Type)
+	assert.NoError(t, json.Unmarshal([]byte(`{"type": ""}`), &scheduling))
+	assert.Error(t, json.Unmarshal([]byte(`{"type": "SomeDevices"}`), &scheduling))
+	assert.NoError(t, yaml.Unmarshal([]byte("type: Conditional"), &scheduling))
+	assert.Equal(t, ScheduleTypeConditional, scheduling.Type)
+	assert.Error(t, yaml.Unmarshal([]byte("type: SomeDevices"), &scheduling))
+
+	var container ContainerSpec
+	assert.NoError(t, json.Unmarshal([]byte(`{"networkMode": "host"}`), &container))
+	assert.Equal(t, NetworkModeHost, container.NetworkMode)
+	assert.Error(t, json.Unmarshal([]byte(`{"networkMode": "overlay"}`), &container))
+	assert.NoError(t, yaml.Unmarshal([]byte("networkMode: bridge"), &container))
+	assert.Equal(t, NetworkModeBridge, container.NetworkMode)
+	assert.Error(t, yaml.Unmarshal([]byte("networkMode: overlay"), &container))
+
+	assert.Len(t, ScheduleTypes(), 3)
+	assert.Len(t, NetworkModes(), 3)
+}
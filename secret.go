@@ -5,7 +5,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,8 +18,27 @@ type ListSecretsRequest struct {
 	Namespace string
 }
 
-// ListSecrets lists all secrets in a namespace
+// ListSecrets lists all secrets in a namespace. Secret data is decoded and decrypted the same way as in GetSecret.
 func (api *API) ListSecrets(ctx context.Context, req *ListSecretsRequest) ([]*Secret, error) {
+	result, err := api.listSecrets(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.secretEncryptor != nil {
+		for _, secret := range result {
+			err = api.decryptSecret(secret)
+			if err != nil {
+				return nil, fmt.Errorf("secret '%s': %w", secret.Name, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// listSecrets lists secrets with decoded but not decrypted data.
+func (api *API) listSecrets(ctx context.Context, req *ListSecretsRequest) ([]*Secret, error) {
 	if req.Namespace == "" {
 		return nil, ErrNamespaceNotSpecified
 	}
@@ -31,12 +53,21 @@ func (api *API) ListSecrets(ctx context.Context, req *ListSecretsRequest) ([]*Se
 	if err != nil {
 		return nil, errors.Wrap(err, errUnmarshalError)
 	}
+	for _, secret := range result {
+		decodeSecret(secret)
+	}
 
 	return result, nil
 }
 
 // CreateSecret creates a secret in a specified namespace. Secret data will be encoded to base64
-// before sending to the API.
+// before sending to the API unless secret Encoding is set to SecretEncodingBase64. If a secret
+// encryptor is configured (see WithSecretEncryptor), data is encrypted first. The returned secret
+// has decoded data, like GetSecret.
+//
+// Note: earlier versions guessed whether Data was already base64 encoded. Data is now always treated
+// as plain text unless Encoding is SecretEncodingBase64, so callers passing base64 encoded data must
+// set Encoding, otherwise the data is encoded twice.
 //
 // Secrets API ref: https://docs.synpse.net/synpse-core/applications/secrets
 func (api *API) CreateSecret(ctx context.Context, namespace string, secret Secret) (*Secret, error) {
@@ -44,7 +75,14 @@ func (api *API) CreateSecret(ctx context.Context, namespace string, secret Secre
 		return nil, ErrNamespaceNotSpecified
 	}
 
-	err := api.ensureSecretEncoding(&secret)
+	if api.secretEncryptor != nil {
+		err := api.encryptSecret(&secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := encodeSecret(&secret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode secret payload: %w", err)
 	}
@@ -59,21 +97,36 @@ func (api *API) CreateSecret(ctx context.Context, namespace string, secret Secre
 	if err != nil {
 		return nil, errors.Wrap(err, errUnmarshalError)
 	}
+	decodeSecret(&result)
 
 	return &result, nil
 }
 
-// ensureSecretEncoding checks whether payload is already base64 encoded. If not, encodes it.
-func (api *API) ensureSecretEncoding(secret *Secret) error {
-	_, err := base64.StdEncoding.DecodeString(secret.Data)
-	if err == nil {
-		// Already encoded, nothing to do
-		return nil
-	}
-
-	// Encode the payload
-	secret.Data = base64.StdEncoding.EncodeToString([]byte(secret.Data))
+// CreateSecretBytes creates a secret from raw data, which can be binary.
+func (api *API) CreateSecretBytes(ctx context.Context, namespace, name string, secretType SecretType, data []byte) (*Secret, error) {
+	return api.CreateSecret(ctx, namespace, Secret{
+		Name:     name,
+		Type:     secretType,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		Encoding: SecretEncodingBase64,
+	})
+}
 
+// encodeSecret base64 encodes plain text secret data. Data that is already base64 encoded is
+// checked and sent as is.
+func encodeSecret(secret *Secret) error {
+	switch secret.Encoding {
+	case SecretEncodingPlain:
+		secret.Data = base64.StdEncoding.EncodeToString([]byte(secret.Data))
+		secret.Encoding = SecretEncodingBase64
+	case SecretEncodingBase64:
+		_, err := base64.StdEncoding.DecodeString(secret.Data)
+		if err != nil {
+			return fmt.Errorf("secret data is not valid base64: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown secret encoding '%s'", secret.Encoding)
+	}
 	return nil
 }
 
@@ -95,7 +148,8 @@ func (api *API) DeleteSecret(ctx context.Context, namespace, name string) error
 	return nil
 }
 
-// UpdateSecret updates a secret data in a specified namespace. Note that secret type cannot be changed.
+// UpdateSecret updates a secret data in a specified namespace. Note that secret type cannot be changed. Data
+// is encoded the same way as in CreateSecret.
 func (api *API) UpdateSecret(ctx context.Context, namespace string, p Secret) (*Secret, error) {
 	if namespace == "" {
 		return nil, ErrNamespaceNotSpecified
@@ -105,7 +159,14 @@ func (api *API) UpdateSecret(ctx context.Context, namespace string, p Secret) (*
 		return nil, fmt.Errorf("secret name not specified")
 	}
 
-	err := api.ensureSecretEncoding(&p)
+	if api.secretEncryptor != nil {
+		err := api.encryptSecret(&p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := encodeSecret(&p)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode secret payload: %w", err)
 	}
@@ -120,12 +181,40 @@ func (api *API) UpdateSecret(ctx context.Context, namespace string, p Secret) (*
 	if err != nil {
 		return nil, errors.Wrap(err, errUnmarshalError)
 	}
+	decodeSecret(&result)
 
 	return &result, nil
 }
 
-// GetSecret gets the secret. Client automatically attempts base64 decoding the secret data.
+// UpdateSecretBytes updates a secret with raw data, which can be binary.
+func (api *API) UpdateSecretBytes(ctx context.Context, namespace, name string, data []byte) (*Secret, error) {
+	return api.UpdateSecret(ctx, namespace, Secret{
+		Name:     name,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		Encoding: SecretEncodingBase64,
+	})
+}
+
+// GetSecret gets the secret. Secret data is decoded from base64, use Secret.Bytes for binary data. If a
+// secret encryptor is configured, client side encrypted data is decrypted.
 func (api *API) GetSecret(ctx context.Context, namespace, name string) (*Secret, error) {
+	result, err := api.getSecret(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.secretEncryptor != nil {
+		err = api.decryptSecret(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// getSecret gets the secret with decoded data.
+func (api *API) getSecret(ctx context.Context, namespace, name string) (*Secret, error) {
 	if namespace == "" {
 		return nil, ErrNamespaceNotSpecified
 	}
@@ -145,14 +234,21 @@ func (api *API) GetSecret(ctx context.Context, namespace, name string) (*Secret,
 		return nil, errors.Wrap(err, errUnmarshalError)
 	}
 
-	decodedData, err := base64.StdEncoding.DecodeString(result.Data)
-	if err == nil {
-		result.Data = string(decodedData)
-	}
+	decodeSecret(&result)
 
 	return &result, nil
 }
 
+// decodeSecret decodes base64 data returned by the API, so that secrets returned by all calls have
+// plain Data. Data that is not valid base64 is kept as is.
+func decodeSecret(secret *Secret) {
+	decoded, err := base64.StdEncoding.DecodeString(secret.Data)
+	if err == nil {
+		secret.Data = string(decoded)
+	}
+	secret.Encoding = SecretEncodingPlain
+}
+
 // Secret is used to conceal sensitive configuration from the deployment manifests. You can create
 // multiple secrets per namespace and use them across one or more applications. Environment type secrets
 // can be used for applications as environment variables or also can be used in Docker registry authentication.
@@ -165,7 +261,64 @@ type Secret struct {
 	NamespaceID string     `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
 	Version     int64      `json:"version,omitempty" yaml:"version,omitempty"`
 	Type        SecretType `json:"type,omitempty" yaml:"type,omitempty"`
-	Data        string     `json:"data,omitempty" yaml:"data,omitempty"` // Base64 encoded data
+	Data        string     `json:"data,omitempty" yaml:"data,omitempty"` // Base64 encoded data when sent to the API
+
+	// Encoding specifies how Data is encoded on the client side. Plain text data is base64 encoded
+	// before sending it to the API. Secrets returned by the API always have plain Data.
+	Encoding SecretEncoding `json:"-" yaml:"-"`
+}
+
+// Bytes returns decoded secret data.
+func (s *Secret) Bytes() ([]byte, error) {
+	switch s.Encoding {
+	case SecretEncodingPlain:
+		return []byte(s.Data), nil
+	case SecretEncodingBase64:
+		return base64.StdEncoding.DecodeString(s.Data)
+	default:
+		return nil, fmt.Errorf("unknown secret encoding '%s'", s.Encoding)
+	}
+}
+
+// SecretEncoding is the client side encoding of the secret data.
+type SecretEncoding string
+
+const (
+	SecretEncodingPlain  SecretEncoding = ""
+	SecretEncodingBase64 SecretEncoding = "base64"
+)
+
+// DefaultMaxSecretFileSize is the file size limit used by NewFileSecret when no limit is specified.
+const DefaultMaxSecretFileSize = 1 << 20
+
+// NewFileSecret creates a File type secret from a file on disk. Files larger than maxSize bytes are
+// rejected, DefaultMaxSecretFileSize is used when maxSize is 0 or negative.
+func NewFileSecret(name, path string, maxSize int64) (*Secret, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSecretFileSize
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Read one byte past the limit to detect oversized files without relying on stat
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file '%s': %w", path, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("secret file '%s' exceeds the %d byte limit", path, maxSize)
+	}
+
+	return &Secret{
+		Name:     name,
+		Type:     SecretTypeFile,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		Encoding: SecretEncodingBase64,
+	}, nil
 }
 
 type SecretType string
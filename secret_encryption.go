@@ -0,0 +1,359 @@
+package synpse
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// encryptedSecretPrefix marks secret data encrypted on the client side. Encrypted data is stored as
+// "synpse-encrypted:v1:<key ID>:<base64 ciphertext>".
+//
+// The key ID is kept in the data rather than in secret metadata because the secrets API has no metadata
+// or annotations field. A field the server doesn't know would be dropped, leaving the secret without a
+// key ID. Keeping it in the data also means the key ID is always updated together with the ciphertext.
+const encryptedSecretPrefix = "synpse-encrypted:v1:"
+
+// SecretEncryptor encrypts secret data on the client side before it's sent to the API. Configure it
+// with WithSecretEncryptor. The key ID is stored in a prefix of the secret data, together with the
+// ciphertext, so that secrets encrypted with previous keys can still be decrypted after a key rotation.
+//
+// Client side encrypted secrets can only be read back through the SDK. The agent passes secret data to
+// applications as is, so workloads that use such a secret in environment variables, files or registry
+// authentication receive the "synpse-encrypted:v1:..." ciphertext. Only use a client with an encryptor
+// for secrets that are consumed by SDK based tooling.
+type SecretEncryptor interface {
+	// KeyID returns the ID of the key used to encrypt new data. It must not contain ':'.
+	KeyID() string
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// encryptSecretData encrypts plain data and wraps it with the key ID.
+func encryptSecretData(encryptor SecretEncryptor, plaintext []byte) (string, error) {
+	keyID := encryptor.KeyID()
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return "", fmt.Errorf("invalid encryption key ID '%s'", keyID)
+	}
+
+	ciphertext, err := encryptor.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedSecretPrefix + keyID + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// parseEncryptedSecretData returns the key ID and ciphertext of client side encrypted data. ok is false
+// if the data is not encrypted.
+func parseEncryptedSecretData(data string) (keyID string, ciphertext []byte, ok bool, err error) {
+	if !strings.HasPrefix(data, encryptedSecretPrefix) {
+		return "", nil, false, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(data, encryptedSecretPrefix), ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", nil, true, fmt.Errorf("malformed encrypted secret data")
+	}
+
+	ciphertext, err = base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, true, fmt.Errorf("malformed encrypted secret data: %w", err)
+	}
+	return parts[0], ciphertext, true, nil
+}
+
+// encryptSecret replaces secret data with its encrypted form.
+func (api *API) encryptSecret(secret *Secret) error {
+	plaintext, err := secret.Bytes()
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptSecretData(api.secretEncryptor, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	secret.Data = encrypted
+	secret.Encoding = SecretEncodingPlain
+	return nil
+}
+
+// decryptSecret decrypts secret data if it was encrypted on the client side.
+func (api *API) decryptSecret(secret *Secret) error {
+	keyID, ciphertext, ok, err := parseEncryptedSecretData(secret.Data)
+	if !ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := api.secretEncryptor.Decrypt(keyID, ciphertext)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secret with key '%s': %w", keyID, err)
+	}
+
+	secret.Data = string(plaintext)
+	secret.Encoding = SecretEncodingPlain
+	return nil
+}
+
+// ReencryptSecret decrypts a client side encrypted secret and encrypts it again with the current encryptor
+// key. Secrets already encrypted with the current key and secrets that were not encrypted on the client
+// side are left unchanged, as encrypting them would break workloads that consume them. Returns true if the
+// secret was updated.
+func (api *API) ReencryptSecret(ctx context.Context, namespace, name string) (bool, error) {
+	if api.secretEncryptor == nil {
+		return false, fmt.Errorf("secret encryptor not configured")
+	}
+
+	raw, err := api.getSecret(ctx, namespace, name)
+	if err != nil {
+		return false, err
+	}
+
+	keyID, _, ok, err := parseEncryptedSecretData(raw.Data)
+	if err != nil {
+		return false, err
+	}
+	if !ok || keyID == api.secretEncryptor.KeyID() {
+		return false, nil
+	}
+
+	err = api.decryptSecret(raw)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = api.UpdateSecret(ctx, namespace, Secret{Name: raw.Name, Data: raw.Data})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReencryptSecretsResult is the result of re-encrypting the secrets in a namespace.
+type ReencryptSecretsResult struct {
+	Updated []string         // Secrets encrypted again with the current key
+	Failed  map[string]error // Errors by secret name, e.g. secrets encrypted with an unknown key
+}
+
+// ReencryptSecrets re-encrypts client side encrypted secrets in the namespace with the current encryptor
+// key, e.g. after a key rotation. Other secrets are not touched. Secrets that fail to re-encrypt, such as
+// secrets encrypted with a key the encryptor doesn't have, are skipped and listed in the result. The
+// returned error is non-nil if any secret failed.
+func (api *API) ReencryptSecrets(ctx context.Context, namespace string) (*ReencryptSecretsResult, error) {
+	if api.secretEncryptor == nil {
+		return nil, fmt.Errorf("secret encryptor not configured")
+	}
+
+	// Secrets are listed without decryption, so that a single secret that can't be decrypted doesn't
+	// prevent re-encrypting the others
+	secrets, err := api.listSecrets(ctx, &ListSecretsRequest{Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReencryptSecretsResult{Failed: make(map[string]error)}
+	for _, secret := range secrets {
+		ok, err := api.ReencryptSecret(ctx, namespace, secret.Name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Failed[secret.Name] = err
+			continue
+		}
+		if ok {
+			result.Updated = append(result.Updated, secret.Name)
+		}
+	}
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("failed to re-encrypt %d of %d secret(s)", len(result.Failed), len(secrets))
+	}
+	return result, nil
+}
+
+// AESGCMEncryptor encrypts secrets with AES-256-GCM. Previous keys can be added to decrypt
+// secrets during a key rotation.
+type AESGCMEncryptor struct {
+	keyID string
+	keys  map[string]cipher.AEAD
+}
+
+// NewAESGCMEncryptor creates an encryptor that encrypts with the current key and decrypts with any of
+// the current or previous keys. Keys must be 32 bytes long. Key IDs are derived from the keys.
+func NewAESGCMEncryptor(current []byte, previous ...[]byte) (*AESGCMEncryptor, error) {
+	e := &AESGCMEncryptor{keys: make(map[string]cipher.AEAD)}
+
+	for i, key := range append([][]byte{current}, previous...) {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid AES key length %d, expected 32 bytes", len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(key)
+		keyID := "aes-" + hex.EncodeToString(sum[:8])
+		if i == 0 {
+			e.keyID = keyID
+		}
+		e.keys[keyID] = aead
+	}
+
+	return e, nil
+}
+
+// NewAESGCMEncryptorFromFiles loads base64 encoded keys from files. The first file holds the current key.
+func NewAESGCMEncryptorFromFiles(current string, previous ...string) (*AESGCMEncryptor, error) {
+	var keys [][]byte
+	for _, path := range append([]string{current}, previous...) {
+		bts, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(bts)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key file '%s': %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewAESGCMEncryptor(keys[0], keys[1:]...)
+}
+
+// GenerateAESKey returns a new random key for NewAESGCMEncryptor.
+func GenerateAESKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, key)
+	return key, err
+}
+
+func (e *AESGCMEncryptor) KeyID() string {
+	return e.keyID
+}
+
+func (e *AESGCMEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	aead := e.keys[e.keyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (e *AESGCMEncryptor) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, ok := e.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key '%s'", keyID)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// AgeEncryptor encrypts secrets for an age X25519 recipient (https://age-encryption.org). Secrets are
+// decrypted with any of the provided identities, so identities of previous recipients can be kept
+// during a key rotation.
+type AgeEncryptor struct {
+	recipient  *age.X25519Recipient
+	identities []age.Identity
+}
+
+// NewAgeEncryptor creates an encryptor from an age public key ("age1...") and private keys
+// ("AGE-SECRET-KEY-1...").
+func NewAgeEncryptor(recipient string, identities ...string) (*AgeEncryptor, error) {
+	r, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &AgeEncryptor{recipient: r}
+	for _, identity := range identities {
+		i, err := age.ParseX25519Identity(identity)
+		if err != nil {
+			return nil, err
+		}
+		e.identities = append(e.identities, i)
+	}
+	return e, nil
+}
+
+// NewAgeEncryptorFromFile creates an encryptor from an age identity file as generated by age-keygen.
+// The first identity in the file is used as the recipient for new secrets.
+func NewAgeEncryptorFromFile(path string) (*AgeEncryptor, error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(bts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity file '%s': %w", path, err)
+	}
+
+	current, ok := identities[0].(*age.X25519Identity)
+	if !ok {
+		return nil, fmt.Errorf("age identity file '%s' must start with an X25519 identity", path)
+	}
+
+	return &AgeEncryptor{recipient: current.Recipient(), identities: identities}, nil
+}
+
+func (e *AgeEncryptor) KeyID() string {
+	return e.recipient.String()
+}
+
+func (e *AgeEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, e.recipient)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *AgeEncryptor) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	if len(e.identities) == 0 {
+		return nil, fmt.Errorf("no age identities configured")
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), e.identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,142 @@
+package synpse
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"path"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESGCMEncryptor(t *testing.T) {
+	oldKey, err := GenerateAESKey()
+	require.NoError(t, err)
+	newKey, err := GenerateAESKey()
+	require.NoError(t, err)
+
+	oldEncryptor, err := NewAESGCMEncryptor(oldKey)
+	require.NoError(t, err)
+
+	encrypted, err := encryptSecretData(oldEncryptor, []byte("s3cr3t"))
+	require.NoError(t, err)
+
+	keyID, ciphertext, ok, err := parseEncryptedSecretData(encrypted)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, oldEncryptor.KeyID(), keyID)
+
+	// Rotated encryptor still decrypts data encrypted with the previous key
+	rotated, err := NewAESGCMEncryptor(newKey, oldKey)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldEncryptor.KeyID(), rotated.KeyID())
+
+	plaintext, err := rotated.Decrypt(keyID, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("s3cr3t"), plaintext)
+
+	_, err = oldEncryptor.Decrypt(rotated.KeyID(), ciphertext)
+	assert.Error(t, err, "expected unknown key error")
+
+	_, err = NewAESGCMEncryptor([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestAgeEncryptor(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	encryptor, err := NewAgeEncryptor(identity.Recipient().String(), identity.String())
+	require.NoError(t, err)
+	assert.Equal(t, identity.Recipient().String(), encryptor.KeyID())
+
+	encrypted, err := encryptSecretData(encryptor, []byte("s3cr3t"))
+	require.NoError(t, err)
+
+	keyID, ciphertext, ok, err := parseEncryptedSecretData(encrypted)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	plaintext, err := encryptor.Decrypt(keyID, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("s3cr3t"), plaintext)
+}
+
+func TestParseEncryptedSecretData(t *testing.T) {
+	_, _, ok, err := parseEncryptedSecretData("plain value")
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	_, _, ok, err = parseEncryptedSecretData(encryptedSecretPrefix + "no-ciphertext")
+	assert.True(t, ok)
+	assert.Error(t, err)
+}
+
+func TestReencryptSecrets(t *testing.T) {
+	oldKey, err := GenerateAESKey()
+	require.NoError(t, err)
+	newKey, err := GenerateAESKey()
+	require.NoError(t, err)
+	unknownKey, err := GenerateAESKey()
+	require.NoError(t, err)
+
+	oldEncryptor, err := NewAESGCMEncryptor(oldKey)
+	require.NoError(t, err)
+	unknownEncryptor, err := NewAESGCMEncryptor(unknownKey)
+	require.NoError(t, err)
+	rotated, err := NewAESGCMEncryptor(newKey, oldKey)
+	require.NoError(t, err)
+
+	oldData, err := encryptSecretData(oldEncryptor, []byte("old"))
+	require.NoError(t, err)
+	unknownData, err := encryptSecretData(unknownEncryptor, []byte("unknown"))
+	require.NoError(t, err)
+
+	// Secrets as stored by the API, with base64 encoded data
+	stored := map[string]*Secret{
+		"plain":   {Name: "plain", Data: base64.StdEncoding.EncodeToString([]byte("plain"))},
+		"old":     {Name: "old", Data: base64.StdEncoding.EncodeToString([]byte(oldData))},
+		"unknown": {Name: "unknown", Data: base64.StdEncoding.EncodeToString([]byte(unknownData))},
+	}
+	var patched []string
+
+	client := getFakeServerClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && name == secretsURL:
+			_ = json.NewEncoder(w).Encode([]*Secret{stored["plain"], stored["old"], stored["unknown"]})
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(stored[name])
+		case r.Method == http.MethodPatch:
+			var secret Secret
+			_ = json.NewDecoder(r.Body).Decode(&secret)
+			stored[name].Data = secret.Data
+			patched = append(patched, name)
+			_ = json.NewEncoder(w).Encode(stored[name])
+		}
+	}))
+	client.secretEncryptor = rotated
+
+	result, err := client.ReencryptSecrets(context.Background(), "default")
+	require.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, []string{"old"}, result.Updated)
+	assert.Len(t, result.Failed, 1)
+	assert.Contains(t, result.Failed, "unknown")
+	assert.Equal(t, []string{"old"}, patched, "only the secret encrypted with the old key should be updated")
+
+	secret, err := client.GetSecret(context.Background(), "default", "old")
+	require.NoError(t, err)
+	assert.Equal(t, "old", secret.Data)
+
+	raw := *stored["old"]
+	decodeSecret(&raw)
+	keyID, _, ok, err := parseEncryptedSecretData(raw.Data)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, rotated.KeyID(), keyID)
+}
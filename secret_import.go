@@ -0,0 +1,409 @@
+package synpse
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SecretCollision specifies what to do when an imported secret already exists.
+type SecretCollision string
+
+const (
+	SecretCollisionSkip      SecretCollision = "skip"
+	SecretCollisionOverwrite SecretCollision = "overwrite"
+	SecretCollisionFail      SecretCollision = "fail" // Default, checked before any secret is created
+)
+
+// ImportSecretsOptions configures secret imports.
+type ImportSecretsOptions struct {
+	OnCollision SecretCollision
+	// DryRun only reports what would be created, updated or skipped.
+	DryRun bool
+	// Prefix is prepended to the generated secret names.
+	Prefix string
+	// MaxFileSize limits the size of imported files, DefaultMaxSecretFileSize is used when not set.
+	MaxFileSize int64
+}
+
+// ImportSecretsResult lists secret names by the import action. In dry-run mode, nothing is changed
+// and the result describes the planned actions.
+type ImportSecretsResult struct {
+	DryRun  bool
+	Created []string
+	Updated []string
+	Skipped []string
+
+	// RegistryAuths are registry credentials wired to the imported secrets, only set by ImportDockerConfig.
+	// Use them as ContainerSpec.Auth.
+	RegistryAuths []DockerAuth
+	// NamespaceRegistry is the registry set as the namespace registry authentication, if any.
+	NamespaceRegistry string
+}
+
+func (r *ImportSecretsResult) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("dry run, no changes made\n")
+	}
+	for _, name := range r.Created {
+		fmt.Fprintf(&b, "create    %s\n", name)
+	}
+	for _, name := range r.Updated {
+		fmt.Fprintf(&b, "overwrite %s\n", name)
+	}
+	for _, name := range r.Skipped {
+		fmt.Fprintf(&b, "skip      %s\n", name)
+	}
+	if r.NamespaceRegistry != "" {
+		fmt.Fprintf(&b, "namespace registry authentication: %s\n", r.NamespaceRegistry)
+	}
+	fmt.Fprintf(&b, "%d created, %d overwritten, %d skipped", len(r.Created), len(r.Updated), len(r.Skipped))
+	return b.String()
+}
+
+// ImportSecrets creates the secrets in the namespace, handling existing secrets as specified
+// in the options.
+func (api *API) ImportSecrets(ctx context.Context, namespace string, secrets []*Secret, opts ImportSecretsOptions) (*ImportSecretsResult, error) {
+	if opts.OnCollision == "" {
+		opts.OnCollision = SecretCollisionFail
+	}
+
+	existing, err := api.ListSecrets(ctx, &ListSecretsRequest{Namespace: namespace})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, secret := range existing {
+		exists[secret.Name] = true
+	}
+
+	result := &ImportSecretsResult{DryRun: opts.DryRun}
+	seen := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		if seen[secret.Name] {
+			return nil, fmt.Errorf("duplicate secret name '%s' in import", secret.Name)
+		}
+		seen[secret.Name] = true
+
+		if !exists[secret.Name] {
+			result.Created = append(result.Created, secret.Name)
+			continue
+		}
+
+		switch opts.OnCollision {
+		case SecretCollisionSkip:
+			result.Skipped = append(result.Skipped, secret.Name)
+		case SecretCollisionOverwrite:
+			result.Updated = append(result.Updated, secret.Name)
+		case SecretCollisionFail:
+			return nil, fmt.Errorf("secret '%s' already exists", secret.Name)
+		default:
+			return nil, fmt.Errorf("unknown collision handling '%s'", opts.OnCollision)
+		}
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	for _, secret := range secrets {
+		switch {
+		case !exists[secret.Name]:
+			_, err = api.CreateSecret(ctx, namespace, *secret)
+		case opts.OnCollision == SecretCollisionOverwrite:
+			_, err = api.UpdateSecret(ctx, namespace, *secret)
+		default:
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to import secret '%s': %w", secret.Name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// ImportEnvFile imports each variable in a .env file as an Environment type secret.
+// See ParseEnvFile for the naming.
+func (api *API) ImportEnvFile(ctx context.Context, namespace, path string, opts ImportSecretsOptions) (*ImportSecretsResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	secrets, err := ParseEnvFile(f, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+
+	return api.ImportSecrets(ctx, namespace, secrets, opts)
+}
+
+// ImportSecretsDir imports each regular file in a directory as a File type secret. See SecretsFromDir
+// for the naming.
+func (api *API) ImportSecretsDir(ctx context.Context, namespace, dir string, opts ImportSecretsOptions) (*ImportSecretsResult, error) {
+	secrets, err := SecretsFromDir(dir, opts.Prefix, opts.MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.ImportSecrets(ctx, namespace, secrets, opts)
+}
+
+// ImportDockerConfig imports registry passwords from a Docker config.json as Environment type secrets.
+// The returned result includes DockerAuth entries referencing the secrets. If namespaceRegistry is set,
+// the matching registry credentials are set as the namespace registry authentication.
+func (api *API) ImportDockerConfig(ctx context.Context, namespace, path, namespaceRegistry string, opts ImportSecretsOptions) (*ImportSecretsResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	credentials, err := ParseDockerConfig(f, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+
+	var (
+		secrets      []*Secret
+		registryAuth *DockerAuth
+	)
+	for _, c := range credentials {
+		secrets = append(secrets, c.Secret)
+		if namespaceRegistry != "" && c.Auth.ServerAddr == namespaceRegistry {
+			auth := c.Auth
+			registryAuth = &auth
+		}
+	}
+	if namespaceRegistry != "" && registryAuth == nil {
+		return nil, fmt.Errorf("registry '%s' not found in '%s'", namespaceRegistry, path)
+	}
+
+	result, err := api.ImportSecrets(ctx, namespace, secrets, opts)
+	if err != nil {
+		return result, err
+	}
+	for _, c := range credentials {
+		result.RegistryAuths = append(result.RegistryAuths, c.Auth)
+	}
+
+	if registryAuth == nil {
+		return result, nil
+	}
+	result.NamespaceRegistry = namespaceRegistry
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	ns, err := api.GetNamespace(ctx, namespace)
+	if err != nil {
+		return result, err
+	}
+	if ns.Config == nil {
+		ns.Config = &NamespaceConfig{}
+	}
+	ns.Config.RegistryAuth = *registryAuth
+
+	_, err = api.UpdateNamespace(ctx, *ns)
+	if err != nil {
+		return result, fmt.Errorf("failed to set namespace registry authentication: %w", err)
+	}
+
+	return result, nil
+}
+
+var envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ParseEnvFile parses KEY=VALUE lines into Environment type secrets. Blank lines, comments and the
+// "export" prefix are ignored, double quoted values are unescaped and single quoted values are taken
+// literally. Secret names are the prefix followed by the lowercased key with underscores replaced by
+// dashes, e.g. DB_PASSWORD becomes db-password.
+func ParseEnvFile(r io.Reader, prefix string) ([]*Secret, error) {
+	var secrets []*Secret
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("line %d: missing '='", lineNum)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		if !envKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid key '%s'", lineNum, key)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(line[idx+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		secrets = append(secrets, &Secret{
+			Name: prefix + secretNameFromKey(key),
+			Type: SecretTypeEnvironment,
+			Data: value,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid double quoted value: %w", err)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("unterminated single quoted value")
+		}
+		return value[1 : len(value)-1], nil
+	default:
+		// Strip inline comments from unquoted values
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		return value, nil
+	}
+}
+
+// SecretsFromDir reads each regular file in the directory into a File type secret. Hidden files and
+// subdirectories are skipped. Secret names are the prefix followed by the lowercased file name with
+// underscores and dots replaced by dashes, e.g. tls.crt becomes tls-crt.
+func SecretsFromDir(dir, prefix string, maxSize int64) ([]*Secret, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []*Secret
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		secret, err := NewFileSecret(prefix+secretNameFromKey(entry.Name()), filepath.Join(dir, entry.Name()), maxSize)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
+// DockerConfigCredential is a registry password imported from a Docker config.json, together with
+// the registry authentication referencing it.
+type DockerConfigCredential struct {
+	Secret *Secret
+	Auth   DockerAuth
+}
+
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// ParseDockerConfig parses registry credentials from a Docker config.json. Credential helpers are not
+// supported, registries without inline credentials are skipped. Secret names are the prefix followed
+// by "registry-" and the sanitized registry address, e.g. registry-quay-io.
+func ParseDockerConfig(r io.Reader, prefix string) ([]*DockerConfigCredential, error) {
+	var cfg dockerConfig
+	err := json.NewDecoder(r).Decode(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	registries := make([]string, 0, len(cfg.Auths))
+	for registry := range cfg.Auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	var credentials []*DockerConfigCredential
+	for _, registry := range registries {
+		entry := cfg.Auths[registry]
+
+		username, password := entry.Username, entry.Password
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for registry '%s': %w", registry, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid auth for registry '%s': expected username:password", registry)
+			}
+			username, password = parts[0], parts[1]
+		}
+		if password == "" {
+			continue
+		}
+
+		name := prefix + "registry-" + secretNameFromKey(registryHost(registry))
+		credentials = append(credentials, &DockerConfigCredential{
+			Secret: &Secret{
+				Name: name,
+				Type: SecretTypeEnvironment,
+				Data: password,
+			},
+			Auth: DockerAuth{
+				Username:   username,
+				ServerAddr: registry,
+				FromSecret: name,
+			},
+		})
+	}
+
+	return credentials, nil
+}
+
+// registryHost strips the scheme and path from registry addresses such as https://index.docker.io/v1/.
+func registryHost(registry string) string {
+	host := registry
+	if idx := strings.Index(host, "://"); idx >= 0 {
+		host = host[idx+3:]
+	}
+	if idx := strings.Index(host, "/"); idx >= 0 {
+		host = host[:idx]
+	}
+	return host
+}
+
+var secretNameInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func secretNameFromKey(key string) string {
+	name := secretNameInvalidChars.ReplaceAllString(strings.ToLower(key), "-")
+	return strings.Trim(name, "-")
+}
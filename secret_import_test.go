@@ -0,0 +1,101 @@
+package synpse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	env := `
+# database
+export DB_PASSWORD=hunter2
+DB_HOST = db.local # inline comment
+GREETING="hello\nworld"
+LITERAL='$NOT_EXPANDED'
+EMPTY=
+`
+	secrets, err := ParseEnvFile(strings.NewReader(env), "app-")
+	require.NoError(t, err)
+	require.Len(t, secrets, 5)
+
+	expected := map[string]string{
+		"app-db-password": "hunter2",
+		"app-db-host":     "db.local",
+		"app-greeting":    "hello\nworld",
+		"app-literal":     "$NOT_EXPANDED",
+		"app-empty":       "",
+	}
+	for _, secret := range secrets {
+		assert.Equal(t, SecretTypeEnvironment, secret.Type)
+		assert.Equal(t, expected[secret.Name], secret.Data, secret.Name)
+	}
+
+	_, err = ParseEnvFile(strings.NewReader("NO_EQUALS"), "")
+	assert.Error(t, err)
+	_, err = ParseEnvFile(strings.NewReader("1KEY=value"), "")
+	assert.Error(t, err)
+	_, err = ParseEnvFile(strings.NewReader("KEY='unterminated"), "")
+	assert.Error(t, err)
+}
+
+func TestSecretsFromDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tls.crt"), []byte("cert"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".hidden"), []byte("hidden"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0700))
+
+	secrets, err := SecretsFromDir(dir, "", 0)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "tls-crt", secrets[0].Name)
+	assert.Equal(t, SecretTypeFile, secrets[0].Type)
+
+	data, err := secrets[0].Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cert"), data)
+
+	_, err = SecretsFromDir(dir, "", 2)
+	assert.Error(t, err, "expected size limit error")
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	cfg := `{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "cm9ib3Q6czNjcjN0"},
+			"quay.io": {"username": "bot", "password": "pa:ss"},
+			"ghcr.io": {}
+		},
+		"credsStore": "desktop"
+	}`
+
+	credentials, err := ParseDockerConfig(strings.NewReader(cfg), "")
+	require.NoError(t, err)
+	require.Len(t, credentials, 2)
+
+	assert.Equal(t, "registry-index-docker-io", credentials[0].Secret.Name)
+	assert.Equal(t, "s3cr3t", credentials[0].Secret.Data)
+	assert.Equal(t, DockerAuth{
+		Username:   "robot",
+		ServerAddr: "https://index.docker.io/v1/",
+		FromSecret: "registry-index-docker-io",
+	}, credentials[0].Auth)
+
+	assert.Equal(t, "registry-quay-io", credentials[1].Secret.Name)
+	assert.Equal(t, "pa:ss", credentials[1].Secret.Data)
+	assert.Equal(t, "bot", credentials[1].Auth.Username)
+}
+
+func TestImportSecretsResultString(t *testing.T) {
+	result := &ImportSecretsResult{
+		DryRun:  true,
+		Created: []string{"db-password"},
+		Skipped: []string{"db-host"},
+	}
+	assert.Equal(t, "dry run, no changes made\ncreate    db-password\nskip      db-host\n1 created, 0 overwritten, 1 skipped", result.String())
+}
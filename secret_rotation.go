@@ -0,0 +1,252 @@
+package synpse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SecretConsumerKind is the kind of workload that references a secret.
+type SecretConsumerKind string
+
+const (
+	SecretConsumerApplication SecretConsumerKind = "Application"
+	SecretConsumerJob         SecretConsumerKind = "Job"
+)
+
+// SecretConsumer is an application or a job that references a secret.
+type SecretConsumer struct {
+	Kind       SecretConsumerKind
+	Name       string
+	Version    int64    // Application or job version, after the redeploy if it was triggered
+	References []string // Where the secret is referenced, e.g. "containers[0].env[PASSWORD]" or "registryAuth"
+
+	Redeployed       bool                        // Redeploy was triggered for the application
+	DeploymentStatus ApplicationDeploymentStatus // Latest application deployment status
+	// Available is set once the server reports the redeployed version of the application and all of its
+	// deployments as available and healthy. Deployment status does not include the version a device runs,
+	// so deployments that have not restarted yet also count as available: it is a best-effort signal, not a
+	// guarantee that every device uses the new secret.
+	Available bool
+}
+
+// RotateSecretOptions configures RotateSecret.
+type RotateSecretOptions struct {
+	// Redeploy triggers a redeploy of the applications that reference the secret. Jobs are only reported.
+	Redeploy bool
+	// Wait waits for redeployed applications to become available on all devices, see SecretConsumer.Available.
+	Wait bool
+	// WaitTimeout limits the wait for all applications. Defaults to 10 minutes.
+	WaitTimeout time.Duration
+	// PollInterval is how often application deployment status is checked. Defaults to 10 seconds.
+	PollInterval time.Duration
+}
+
+// RotateSecretResult is the result of a secret rotation.
+type RotateSecretResult struct {
+	Secret    *Secret
+	Consumers []*SecretConsumer
+	// RegistryAuth is true when the namespace registry authentication uses the secret. All container
+	// applications and jobs in the namespace are then consumers, and applications are redeployed too.
+	RegistryAuth bool
+}
+
+// RotateSecret updates the secret data, finds applications and jobs in the namespace that reference the
+// secret and optionally redeploys the applications and waits for their deployments to be available.
+func (api *API) RotateSecret(ctx context.Context, namespace, name, newData string, opts RotateSecretOptions) (*RotateSecretResult, error) {
+	if opts.WaitTimeout == 0 {
+		opts.WaitTimeout = 10 * time.Minute
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 10 * time.Second
+	}
+
+	secret, err := api.UpdateSecret(ctx, namespace, Secret{Name: name, Data: newData})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	result := &RotateSecretResult{Secret: secret}
+
+	result.Consumers, result.RegistryAuth, err = api.findSecretConsumers(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Redeploy {
+		return result, nil
+	}
+
+	for _, consumer := range result.Consumers {
+		if consumer.Kind != SecretConsumerApplication {
+			continue
+		}
+
+		application, err := api.GetApplication(ctx, namespace, consumer.Name)
+		if err != nil {
+			return result, fmt.Errorf("failed to get application '%s': %w", consumer.Name, err)
+		}
+
+		application, err = api.UpdateApplication(ctx, namespace, *application)
+		if err != nil {
+			return result, fmt.Errorf("failed to redeploy application '%s': %w", consumer.Name, err)
+		}
+		consumer.Redeployed = true
+		consumer.Version = application.Version
+		consumer.DeploymentStatus = application.DeploymentStatus
+	}
+
+	if !opts.Wait {
+		return result, nil
+	}
+
+	return result, api.waitForSecretConsumers(ctx, namespace, result.Consumers, opts)
+}
+
+func (api *API) waitForSecretConsumers(ctx context.Context, namespace string, consumers []*SecretConsumer, opts RotateSecretOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, opts.WaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := 0
+		for _, consumer := range consumers {
+			if !consumer.Redeployed || consumer.Available {
+				continue
+			}
+
+			application, err := api.GetApplication(ctx, namespace, consumer.Name)
+			if err == nil {
+				consumer.DeploymentStatus = application.DeploymentStatus
+				status := application.DeploymentStatus
+				consumer.Available = application.Version >= consumer.Version &&
+					status.Pending == 0 && status.Available >= status.Total && status.Unhealthy == 0
+			}
+			if !consumer.Available {
+				pending++
+			}
+		}
+
+		if pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d application(s) not available: %w", pending, ctx.Err())
+		}
+	}
+}
+
+// FindSecretConsumers lists applications and jobs in the namespace that reference the secret in container
+// secrets, environment variables, registry authentication or systemd files. If the namespace registry
+// authentication uses the secret, all container applications and jobs are consumers.
+func (api *API) FindSecretConsumers(ctx context.Context, namespace, name string) ([]*SecretConsumer, error) {
+	consumers, _, err := api.findSecretConsumers(ctx, namespace, name)
+	return consumers, err
+}
+
+func (api *API) findSecretConsumers(ctx context.Context, namespace, name string) ([]*SecretConsumer, bool, error) {
+	ns, err := api.GetNamespace(ctx, namespace)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get namespace: %w", err)
+	}
+	registryAuth := ns.Config != nil && ns.Config.RegistryAuth.FromSecret == name
+
+	applications, err := api.ListApplications(ctx, &ListApplicationsRequest{Namespace: namespace})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list applications: %w", err)
+	}
+
+	jobs, err := api.ListJobs(ctx, ListJobsRequest{Namespace: namespace})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	return secretConsumers(applications, jobs, name, registryAuth), registryAuth, nil
+}
+
+// registryAuthReference is reported for workloads that use the secret through namespace registry authentication.
+const registryAuthReference = "registryAuth"
+
+func secretConsumers(applications []*Application, jobs []*Job, name string, registryAuth bool) []*SecretConsumer {
+	var consumers []*SecretConsumer
+	for _, application := range applications {
+		refs := containerSecretReferences(application.Spec.ContainerSpec, name)
+		refs = append(refs, systemdSecretReferences(application.Spec.SystemdSpec, name)...)
+		if registryAuth && len(application.Spec.ContainerSpec) > 0 {
+			refs = append(refs, registryAuthReference)
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		consumers = append(consumers, &SecretConsumer{
+			Kind:             SecretConsumerApplication,
+			Name:             application.Name,
+			Version:          application.Version,
+			References:       refs,
+			DeploymentStatus: application.DeploymentStatus,
+		})
+	}
+
+	for _, job := range jobs {
+		refs := containerSecretReferences(job.Spec.ContainerSpec, name)
+		if registryAuth && len(job.Spec.ContainerSpec) > 0 {
+			refs = append(refs, registryAuthReference)
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		consumers = append(consumers, &SecretConsumer{
+			Kind:       SecretConsumerJob,
+			Name:       job.Name,
+			Version:    job.Version,
+			References: refs,
+		})
+	}
+
+	return consumers
+}
+
+func containerSecretReferences(containers []ContainerSpec, name string) []string {
+	var refs []string
+	for i, container := range containers {
+		prefix := fmt.Sprintf("containers[%d]", i)
+		for _, secret := range container.Secrets {
+			if secret.Name == name {
+				refs = append(refs, prefix+".secrets["+secret.Filepath+"]")
+			}
+		}
+		for _, env := range container.Environment {
+			if env.FromSecret == name {
+				refs = append(refs, prefix+".env["+env.Name+"]")
+			}
+		}
+		if container.Auth != nil && container.Auth.FromSecret == name {
+			refs = append(refs, prefix+".auth")
+		}
+	}
+	return refs
+}
+
+func systemdSecretReferences(spec *SystemdSpec, name string) []string {
+	if spec == nil {
+		return nil
+	}
+
+	var refs []string
+	for _, env := range spec.Environment {
+		if env.FromSecret == name {
+			refs = append(refs, "systemd.env["+env.Name+"]")
+		}
+	}
+	for _, file := range spec.Files {
+		if file.FromSecret == name {
+			refs = append(refs, "systemd.files["+file.Path+"]")
+		}
+	}
+	return refs
+}
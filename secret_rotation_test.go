@@ -0,0 +1,61 @@
+package synpse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretReferences(t *testing.T) {
+	containers := []ContainerSpec{
+		{
+			Name:        "app",
+			Auth:        &DockerAuth{Username: "robot", FromSecret: "registry"},
+			Environment: Environments{{Name: "PASSWORD", FromSecret: "db"}, {Name: "HOST", Value: "db"}},
+		},
+		{
+			Name:    "sidecar",
+			Secrets: []SecretRef{{Name: "db", Filepath: "/run/secrets/db"}},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"containers[0].env[PASSWORD]",
+		"containers[1].secrets[/run/secrets/db]",
+	}, containerSecretReferences(containers, "db"))
+	assert.Equal(t, []string{"containers[0].auth"}, containerSecretReferences(containers, "registry"))
+	assert.Empty(t, containerSecretReferences(containers, "other"))
+
+	systemd := &SystemdSpec{
+		Environment: Environments{{Name: "TOKEN", FromSecret: "token"}},
+		Files:       []SystemdFile{{Path: "/etc/app/token", FromSecret: "token"}},
+	}
+	assert.Equal(t, []string{"systemd.env[TOKEN]", "systemd.files[/etc/app/token]"}, systemdSecretReferences(systemd, "token"))
+	assert.Empty(t, systemdSecretReferences(nil, "token"))
+}
+
+func TestSecretConsumers(t *testing.T) {
+	applications := []*Application{
+		{Name: "api", Version: 3, Spec: ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "api", Environment: Environments{{Name: "TOKEN", FromSecret: "token"}}}}}},
+		{Name: "web", Version: 1, Spec: ApplicationSpec{ContainerSpec: []ContainerSpec{{Name: "web"}}}},
+		{Name: "collector", Type: RuntimeSystemd, Spec: ApplicationSpec{SystemdSpec: &SystemdSpec{UnitName: "collector"}}},
+	}
+	jobs := []*Job{
+		{Name: "backup", Spec: JobSpec{ContainerSpec: []ContainerSpec{{Name: "backup"}}}},
+	}
+
+	consumers := secretConsumers(applications, jobs, "token", false)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "api", consumers[0].Name)
+	assert.Equal(t, int64(3), consumers[0].Version)
+	assert.Equal(t, []string{"containers[0].env[TOKEN]"}, consumers[0].References)
+
+	// Registry authentication makes every container workload a consumer
+	consumers = secretConsumers(applications, jobs, "token", true)
+	require.Len(t, consumers, 3)
+	assert.Equal(t, []string{"containers[0].env[TOKEN]", "registryAuth"}, consumers[0].References)
+	assert.Equal(t, "web", consumers[1].Name)
+	assert.Equal(t, []string{"registryAuth"}, consumers[1].References)
+	assert.Equal(t, SecretConsumerJob, consumers[2].Kind)
+}
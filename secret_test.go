@@ -2,6 +2,8 @@ package synpse
 
 import (
 	"context"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/segmentio/ksuid"
@@ -62,3 +64,59 @@ func TestEnvironmentSecret(t *testing.T) {
 		require.Error(t, err, "expected to get an error")
 	})
 }
+
+func TestEncodeSecret(t *testing.T) {
+	// Plain text that happens to be valid base64 is still encoded
+	secret := Secret{Name: "plain", Data: "abcd"}
+	require.NoError(t, encodeSecret(&secret))
+	assert.Equal(t, "YWJjZA==", secret.Data)
+	assert.Equal(t, SecretEncodingBase64, secret.Encoding)
+
+	data, err := secret.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcd"), data)
+
+	encoded := Secret{Name: "encoded", Data: "YWJjZA==", Encoding: SecretEncodingBase64}
+	require.NoError(t, encodeSecret(&encoded))
+	assert.Equal(t, "YWJjZA==", encoded.Data)
+
+	invalid := Secret{Name: "invalid", Data: "not base64!", Encoding: SecretEncodingBase64}
+	assert.Error(t, encodeSecret(&invalid))
+
+	unknown := Secret{Name: "unknown", Data: "abcd", Encoding: "hex"}
+	assert.Error(t, encodeSecret(&unknown))
+}
+
+func TestNewFileSecret(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cert.der")
+	payload := []byte{0x30, 0x82, 0x00, 0xff, 0xfe}
+	require.NoError(t, ioutil.WriteFile(path, payload, 0600))
+
+	secret, err := NewFileSecret("cert", path, 0)
+	require.NoError(t, err)
+	assert.Equal(t, SecretTypeFile, secret.Type)
+
+	data, err := secret.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+
+	_, err = NewFileSecret("cert", path, int64(len(payload)-1))
+	assert.Error(t, err, "expected size limit error")
+
+	_, err = NewFileSecret("cert", filepath.Join(dir, "missing"), 0)
+	assert.Error(t, err)
+}
+
+func TestDecodeSecret(t *testing.T) {
+	secret := Secret{Data: "YWJjZA==", Encoding: SecretEncodingBase64}
+	decodeSecret(&secret)
+	assert.Equal(t, "abcd", secret.Data)
+	assert.Equal(t, SecretEncodingPlain, secret.Encoding)
+
+	// Data stored without encoding is returned unchanged
+	raw := Secret{Data: "not base64!"}
+	decodeSecret(&raw)
+	assert.Equal(t, "not base64!", raw.Data)
+	assert.Equal(t, SecretEncodingPlain, raw.Encoding)
+}
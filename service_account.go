@@ -0,0 +1,269 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ServiceAccount is a non-human identity inside a project, e.g. for CI pipelines. Service accounts
+// authenticate with access keys and get their permissions from bound roles.
+type ServiceAccount struct {
+	ID          string    `json:"id" yaml:"id"`
+	CreatedAt   time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" yaml:"updatedAt"`
+	ProjectID   string    `json:"projectId" yaml:"projectId"`
+	Name        string    `json:"name" yaml:"name"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// extra data fields (optional)
+	Roles []Role `json:"roles,omitempty" yaml:"roles,omitempty"`
+}
+
+// ServiceAccountAccessKey is an access key of a service account. Secret is only returned when the key
+// is created and must be stored by the caller.
+type ServiceAccountAccessKey struct {
+	ID               string     `json:"id" yaml:"id"`
+	CreatedAt        time.Time  `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt" yaml:"updatedAt"`
+	ServiceAccountID string     `json:"serviceAccountId" yaml:"serviceAccountId"`
+	Description      string     `json:"description,omitempty" yaml:"description,omitempty"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty" yaml:"lastUsedAt,omitempty"` // read-only
+
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"` // read-only, only set on create
+}
+
+// Expired returns true if the key has an expiry time that is not after now.
+func (k *ServiceAccountAccessKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
+
+// ListServiceAccounts returns service accounts in the current project together with their roles.
+func (api *API) ListServiceAccounts(ctx context.Context) ([]*ServiceAccount, error) {
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL+"?full"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*ServiceAccount
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return result, nil
+}
+
+// GetServiceAccount returns service account by ID.
+func (api *API) GetServiceAccount(ctx context.Context, serviceAccountID string) (*ServiceAccount, error) {
+	if serviceAccountID == "" {
+		return nil, fmt.Errorf("service account ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID+"?full"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ServiceAccount
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// CreateServiceAccount creates a new service account in the current project.
+func (api *API) CreateServiceAccount(ctx context.Context, serviceAccount ServiceAccount) (*ServiceAccount, error) {
+	if serviceAccount.Name == "" {
+		return nil, fmt.Errorf("service account name not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL), serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ServiceAccount
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// DeleteServiceAccount deletes the service account, its access keys and role bindings.
+func (api *API) DeleteServiceAccount(ctx context.Context, serviceAccountID string) error {
+	if serviceAccountID == "" {
+		return fmt.Errorf("service account ID not specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID), nil)
+	return err
+}
+
+// ListServiceAccountAccessKeys returns access keys of the service account. Secrets are not included.
+func (api *API) ListServiceAccountAccessKeys(ctx context.Context, serviceAccountID string) ([]*ServiceAccountAccessKey, error) {
+	if serviceAccountID == "" {
+		return nil, fmt.Errorf("service account ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID, accessKeysURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*ServiceAccountAccessKey
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return result, nil
+}
+
+// CreateServiceAccountAccessKey issues a new access key for the service account. The returned key
+// contains the secret, which cannot be retrieved again.
+func (api *API) CreateServiceAccountAccessKey(ctx context.Context, serviceAccountID string, accessKey ServiceAccountAccessKey) (*ServiceAccountAccessKey, error) {
+	if serviceAccountID == "" {
+		return nil, fmt.Errorf("service account ID not specified")
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPost, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID, accessKeysURL), accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ServiceAccountAccessKey
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// UpdateServiceAccountAccessKey updates access key description and expiry time, other fields are not sent.
+// A nil ExpiresAt removes the expiry.
+func (api *API) UpdateServiceAccountAccessKey(ctx context.Context, serviceAccountID string, accessKey ServiceAccountAccessKey) (*ServiceAccountAccessKey, error) {
+	if serviceAccountID == "" || accessKey.ID == "" {
+		return nil, fmt.Errorf("service account ID and access key ID must be specified")
+	}
+
+	patch := map[string]interface{}{
+		"description": accessKey.Description,
+		"expiresAt":   accessKey.ExpiresAt,
+	}
+
+	resp, _, err := api.makeRequestContext(ctx, http.MethodPatch, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID, accessKeysURL, accessKey.ID), patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ServiceAccountAccessKey
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	return &result, nil
+}
+
+// RevokeServiceAccountAccessKey deletes the access key. Requests made with it are rejected immediately.
+func (api *API) RevokeServiceAccountAccessKey(ctx context.Context, serviceAccountID, accessKeyID string) error {
+	if serviceAccountID == "" || accessKeyID == "" {
+		return fmt.Errorf("service account ID and access key ID must be specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID, accessKeysURL, accessKeyID), nil)
+	return err
+}
+
+// RotateServiceAccountAccessKey issues a new access key and retires the old one. With a zero overlap the
+// old key is revoked immediately, otherwise it is set to expire after the overlap so that consumers can
+// switch to the new key. The returned key contains the new secret.
+func (api *API) RotateServiceAccountAccessKey(ctx context.Context, serviceAccountID, oldAccessKeyID string, overlap time.Duration) (*ServiceAccountAccessKey, error) {
+	if oldAccessKeyID == "" {
+		return nil, fmt.Errorf("access key ID not specified")
+	}
+
+	keys, err := api.ListServiceAccountAccessKeys(ctx, serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var old *ServiceAccountAccessKey
+	for _, key := range keys {
+		if key.ID == oldAccessKeyID {
+			old = key
+			break
+		}
+	}
+	if old == nil {
+		return nil, fmt.Errorf("access key '%s' of service account '%s': %w", oldAccessKeyID, serviceAccountID, ErrNotFound)
+	}
+
+	created, err := api.CreateServiceAccountAccessKey(ctx, serviceAccountID, ServiceAccountAccessKey{
+		Description: old.Description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access key: %w", err)
+	}
+
+	revoke, expiresAt := accessKeyRetirement(old, overlap, time.Now())
+	if revoke {
+		err = api.RevokeServiceAccountAccessKey(ctx, serviceAccountID, old.ID)
+		if err != nil {
+			return created, fmt.Errorf("failed to revoke old access key '%s': %w", old.ID, err)
+		}
+		return created, nil
+	}
+	if expiresAt == nil {
+		return created, nil
+	}
+
+	old.ExpiresAt = expiresAt
+	_, err = api.UpdateServiceAccountAccessKey(ctx, serviceAccountID, *old)
+	if err != nil {
+		return created, fmt.Errorf("failed to set old access key '%s' expiry: %w", old.ID, err)
+	}
+	return created, nil
+}
+
+// accessKeyRetirement decides how the old key is retired on rotation: it is revoked with a zero overlap,
+// otherwise it gets the returned expiry time. The expiry is nil if the key already expires sooner, as the
+// lifetime of the old key is never extended.
+func accessKeyRetirement(old *ServiceAccountAccessKey, overlap time.Duration, now time.Time) (bool, *time.Time) {
+	if overlap <= 0 {
+		return true, nil
+	}
+
+	expiresAt := now.Add(overlap)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(expiresAt) {
+		return false, nil
+	}
+	return false, &expiresAt
+}
+
+// BindServiceAccountRole binds the role to the service account.
+func (api *API) BindServiceAccountRole(ctx context.Context, serviceAccountID, roleID string) error {
+	if serviceAccountID == "" || roleID == "" {
+		return fmt.Errorf("service account ID and role ID must be specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodPut, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID, rolesURL, roleID), nil)
+	return err
+}
+
+// UnbindServiceAccountRole removes the role from the service account.
+func (api *API) UnbindServiceAccountRole(ctx context.Context, serviceAccountID, roleID string) error {
+	if serviceAccountID == "" || roleID == "" {
+		return fmt.Errorf("service account ID and role ID must be specified")
+	}
+	_, _, err := api.makeRequestContext(ctx, http.MethodDelete, getURL(api.BaseURL, projectsURL, api.ProjectID, serviceAccountsURL, serviceAccountID, rolesURL, roleID), nil)
+	return err
+}
@@ -0,0 +1,65 @@
+package synpse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccountAccessKeyExpired(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		want      bool
+	}{
+		{"NoExpiry", nil, false},
+		{"Past", &past, true},
+		{"Now", &now, true},
+		{"Future", &future, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := ServiceAccountAccessKey{ExpiresAt: tt.expiresAt}
+			assert.Equal(t, tt.want, key.Expired(now))
+		})
+	}
+}
+
+func TestAccessKeyRetirement(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	soon := now.Add(time.Minute)
+	later := now.Add(48 * time.Hour)
+
+	t.Run("ZeroOverlapRevokes", func(t *testing.T) {
+		revoke, expiresAt := accessKeyRetirement(&ServiceAccountAccessKey{}, 0, now)
+		assert.True(t, revoke)
+		assert.Nil(t, expiresAt)
+	})
+
+	t.Run("OverlapSetsExpiry", func(t *testing.T) {
+		revoke, expiresAt := accessKeyRetirement(&ServiceAccountAccessKey{}, time.Hour, now)
+		assert.False(t, revoke)
+		require.NotNil(t, expiresAt)
+		assert.Equal(t, now.Add(time.Hour), *expiresAt)
+	})
+
+	t.Run("OverlapShortensLaterExpiry", func(t *testing.T) {
+		revoke, expiresAt := accessKeyRetirement(&ServiceAccountAccessKey{ExpiresAt: &later}, time.Hour, now)
+		assert.False(t, revoke)
+		require.NotNil(t, expiresAt)
+		assert.Equal(t, now.Add(time.Hour), *expiresAt)
+	})
+
+	t.Run("EarlierExpiryKept", func(t *testing.T) {
+		revoke, expiresAt := accessKeyRetirement(&ServiceAccountAccessKey{ExpiresAt: &soon}, time.Hour, now)
+		assert.False(t, revoke)
+		assert.Nil(t, expiresAt)
+	})
+}
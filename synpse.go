@@ -33,10 +33,21 @@ const (
 	ClientClientRequestID = "synpse-client-request-id"
 )
 
+// credentialValidationTimeout bounds the credential check done by New and NewWithProject when
+// WithCredentialValidation is set.
+const credentialValidationTimeout = 30 * time.Second
+
 // Errors
 var (
 	ErrEmptyCredentials      = errors.New("invalid credentials: access key must not be empty")
 	ErrNamespaceNotSpecified = errors.New("namespace not specified")
+	ErrNotFound              = errors.New("not found")
+	ErrRolloutFailed         = errors.New("rollout failed")
+	ErrQuotaExceeded         = errors.New("project quota exceeded")
+	ErrConflict              = errors.New("resource was modified concurrently")
+	ErrUnauthorized          = errors.New("invalid credentials")
+	ErrForbidden             = errors.New("insufficient permissions")
+	ErrStatusConflict        = errors.New("conflict with the current state of the resource")
 )
 
 // Error messages
@@ -59,9 +70,15 @@ const (
 	sshURL                     = "ssh"
 	connectURL                 = "connect"
 	rebootURL                  = "reboot"
+	revokeURL                  = "revoke"
 	membershipsURL             = "memberships"
 	secretsURL                 = "secrets"
 	logsURL                    = "logs"
+	revisionsURL               = "revisions"
+	rolesURL                   = "roles"
+	serviceAccountsURL         = "service-accounts"
+	accessKeysURL              = "access-keys"
+	meURL                      = "me"
 )
 
 // New creates a new Synpse v1 API client.
@@ -77,6 +94,16 @@ func New(accessKey string, opts ...Option) (*API, error) {
 
 	api.APIAccessKey = accessKey
 
+	if api.validateOnCreate {
+		ctx, cancel := context.WithTimeout(context.Background(), credentialValidationTimeout)
+		defer cancel()
+
+		err = api.validateCredentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return api, nil
 }
 
@@ -94,11 +121,21 @@ func NewWithProject(accessKey, projectID string, opts ...Option) (*API, error) {
 	api.APIAccessKey = accessKey
 	api.ProjectID = projectID
 
+	if api.validateOnCreate {
+		ctx, cancel := context.WithTimeout(context.Background(), credentialValidationTimeout)
+		defer cancel()
+
+		err = api.validateCredentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return api, nil
 }
 
 // API holds the configuration for the current API client. A client should not
-// be modified concurrently.
+// be modified concurrently, use Project to get a client for another project.
 type API struct {
 	APIAccessKey string
 	BaseURL      string
@@ -111,6 +148,11 @@ type API struct {
 	retryPolicy RetryPolicy
 	rateLimiter *rate.Limiter
 	logger      Logger
+
+	revisionStore    RevisionStore
+	revisionSupport  *revisionSupport
+	secretEncryptor  SecretEncryptor
+	validateOnCreate bool
 }
 
 // newClient provides shared logic
@@ -128,7 +170,14 @@ func newClient(opts ...Option) (*API, error) {
 			MinRetryDelay: time.Duration(1) * time.Second,
 			MaxRetryDelay: time.Duration(30) * time.Second,
 		},
-		logger: silentLogger,
+		logger:          silentLogger,
+		revisionSupport: &revisionSupport{},
+	}
+
+	// Application revisions are kept in the user cache directory by default, they are only used when
+	// the server doesn't retain application history
+	if store, err := NewDirRevisionStore(""); err == nil {
+		api.revisionStore = store
 	}
 
 	err := api.parseOptions(opts...)
@@ -252,11 +301,13 @@ func (api *API) makeRequestWithAuthTypeAndHeaders(ctx context.Context, method, u
 	switch {
 	case resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
 	case resp.StatusCode == http.StatusUnauthorized:
-		return nil, resp.Header, errors.Errorf("HTTP status %d: invalid credentials", resp.StatusCode)
+		return nil, resp.Header, errors.Wrapf(ErrUnauthorized, "HTTP status %d", resp.StatusCode)
 	case resp.StatusCode == http.StatusForbidden:
-		return nil, resp.Header, errors.Errorf("HTTP status %d: insufficient permissions", resp.StatusCode)
+		return nil, resp.Header, errors.Wrapf(ErrForbidden, "HTTP status %d", resp.StatusCode)
 	case resp.StatusCode == http.StatusPreconditionFailed:
-		return nil, resp.Header, errors.Errorf("HTTP status %d: precondition failed", resp.StatusCode)
+		return nil, resp.Header, errors.Wrapf(ErrConflict, "HTTP status %d: precondition failed", resp.StatusCode)
+	case resp.StatusCode == http.StatusConflict:
+		return nil, resp.Header, errors.Wrapf(ErrStatusConflict, "HTTP status %d: content %q", resp.StatusCode, respBody)
 	case resp.StatusCode == http.StatusPaymentRequired:
 		return nil, resp.Header, errors.Errorf("HTTP status %d: feature not available for your subscription", resp.StatusCode)
 	case resp.StatusCode == http.StatusServiceUnavailable,
@@ -266,6 +317,8 @@ func (api *API) makeRequestWithAuthTypeAndHeaders(ctx context.Context, method, u
 		resp.StatusCode == 523,
 		resp.StatusCode == 524:
 		return nil, resp.Header, errors.Errorf("HTTP status %d: service failure", resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, resp.Header, errors.Wrapf(ErrNotFound, "HTTP status %d: content %q", resp.StatusCode, respBody)
 	case resp.StatusCode == 400:
 		return nil, resp.Header, errors.Errorf("%s", respBody)
 	default:
@@ -279,6 +332,12 @@ func (api *API) makeRequestWithAuthTypeAndHeaders(ctx context.Context, method, u
 	return respBody, resp.Header, nil
 }
 
+// isPermanentError returns true for errors that retrying the request won't fix, such as a missing
+// resource or insufficient permissions.
+func isPermanentError(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden)
+}
+
 // request makes a HTTP request to the given API endpoint, returning the raw
 // *http.Response, or an error if one occurred. The caller is responsible for
 // closing the response body.
@@ -1,10 +1,13 @@
 package synpse
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -33,6 +36,19 @@ func init() {
 	sdkTestApplicationName = os.Getenv(EnvSynpseSDKTestApplicationName)
 }
 
+// getFakeServerClient returns a new API client that sends requests to a
+// fake server using the handler. Retries and rate limiting are disabled.
+func getFakeServerClient(t *testing.T, handler http.Handler) *API {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	apiClient, err := NewWithProject("test-access-key", "test-project", WithAPIEndpointURL(server.URL), WithRetryPolicy(0, 0, 0))
+	require.NoError(t, err, "failed to create API client")
+	apiClient.rateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	return apiClient
+}
+
 // getTestingClient returns a new API client for testing purposes. This
 // client should be using project access keys.
 func getTestingProjectClient(t *testing.T) *API {
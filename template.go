@@ -0,0 +1,148 @@
+package synpse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Template delimiters used in application specs. Go template syntax is used with "${{" instead of "{{", so
+// that values meant for Docker or the application itself, such as a log tag "{{.Name}}", are left as is.
+const (
+	templateLeftDelim  = "${{"
+	templateRightDelim = "}}"
+)
+
+// TemplateData is the data available when rendering application templates. String fields in the
+// application spec can reference it using Go template syntax with "${{" as the opening delimiter,
+// e.g. "${{ .Device.Labels.site }}" or "${{ .Params.mqttTopic }}".
+type TemplateData struct {
+	Device *Device
+	Params map[string]string
+}
+
+// TemplateError is returned when one or more fields of the application spec fail to render,
+// for example because they reference a missing label or parameter.
+type TemplateError struct {
+	Fields map[string]error // Field path, e.g. "containers[0].env[1].value"
+}
+
+func (e *TemplateError) Error() string {
+	paths := make([]string, 0, len(e.Fields))
+	for p := range e.Fields {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	msgs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", p, e.Fields[p]))
+	}
+	return "failed to render application template: " + strings.Join(msgs, "; ")
+}
+
+// RenderApplication renders template expressions in the application spec. The original application is
+// not modified. Missing map keys, such as labels that are not set on the device, are reported as errors.
+func RenderApplication(application Application, data TemplateData) (*Application, error) {
+	if data.Device == nil {
+		data.Device = &Device{}
+	}
+
+	bts, err := json.Marshal(application.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode application spec: %w", err)
+	}
+
+	var spec interface{}
+	err = json.Unmarshal(bts, &spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode application spec: %w", err)
+	}
+
+	templateErr := &TemplateError{Fields: make(map[string]error)}
+	spec = renderValue("", spec, data, templateErr)
+	if len(templateErr.Fields) > 0 {
+		return nil, templateErr
+	}
+
+	bts, err = json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rendered application spec: %w", err)
+	}
+
+	var rendered ApplicationSpec
+	err = json.Unmarshal(bts, &rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered application spec: %w", err)
+	}
+
+	application.Spec = rendered
+
+	return &application, nil
+}
+
+func renderValue(path string, v interface{}, data TemplateData, templateErr *TemplateError) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			val[k] = renderValue(p, item, data, templateErr)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = renderValue(path+"["+strconv.Itoa(i)+"]", item, data, templateErr)
+		}
+		return val
+	case string:
+		if !strings.Contains(val, templateLeftDelim) {
+			return val
+		}
+		rendered, err := renderString(val, data)
+		if err != nil {
+			templateErr.Fields[path] = err
+			return val
+		}
+		return rendered
+	default:
+		return v
+	}
+}
+
+func renderString(text string, data TemplateData) (string, error) {
+	tmpl, err := template.New("").Delims(templateLeftDelim, templateRightDelim).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// PreviewApplication renders the application spec, containers or systemd unit, for the specified device
+// without creating or updating the application.
+func (api *API) PreviewApplication(ctx context.Context, application Application, device string, params map[string]string) (*ApplicationSpec, error) {
+	d, err := api.GetDevice(ctx, device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device '%s': %w", device, err)
+	}
+
+	rendered, err := RenderApplication(application, TemplateData{Device: d, Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rendered.Spec, nil
+}
@@ -0,0 +1,80 @@
+package synpse
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderApplication(t *testing.T) {
+	application := Application{
+		Name: "mqtt-bridge",
+		Spec: ApplicationSpec{
+			ContainerSpec: []ContainerSpec{
+				{
+					Name:  "bridge",
+					Image: "quay.io/synpse/mqtt-bridge:${{ .Params.version }}",
+					Environment: Environments{
+						{Name: "SITE_ID", Value: "${{ .Device.Labels.site }}"},
+						{Name: "TOPIC", Value: "sites/${{ .Device.Labels.site }}/${{ .Device.Name }}"},
+					},
+					LogConfig: &LogConfig{Driver: "json-file", Options: map[string]string{"tag": "{{.Name}}"}},
+				},
+			},
+		},
+	}
+
+	device := &Device{
+		Name:   "gateway-1",
+		Labels: map[string]string{"site": "riga"},
+	}
+
+	t.Run("Render", func(t *testing.T) {
+		rendered, err := RenderApplication(application, TemplateData{
+			Device: device,
+			Params: map[string]string{"version": "1.2.0"},
+		})
+		require.NoError(t, err)
+
+		container := rendered.Spec.ContainerSpec[0]
+		assert.Equal(t, "quay.io/synpse/mqtt-bridge:1.2.0", container.Image)
+		assert.Equal(t, "riga", container.Environment[0].Value)
+		assert.Equal(t, "sites/riga/gateway-1", container.Environment[1].Value)
+		assert.Equal(t, "{{.Name}}", container.LogConfig.Options["tag"], "Docker templates must be left as is")
+
+		// Original application is left untouched
+		assert.Equal(t, "${{ .Device.Labels.site }}", application.Spec.ContainerSpec[0].Environment[0].Value)
+	})
+
+	t.Run("MissingVariables", func(t *testing.T) {
+		_, err := RenderApplication(application, TemplateData{
+			Device: &Device{Name: "gateway-2"},
+		})
+		require.Error(t, err)
+
+		var templateErr *TemplateError
+		require.True(t, errors.As(err, &templateErr))
+		assert.Contains(t, templateErr.Fields, "containers[0].image")
+		assert.Contains(t, templateErr.Fields, "containers[0].env[0].value")
+		assert.Contains(t, templateErr.Fields, "containers[0].env[1].value")
+	})
+}
+
+func TestRenderSystemdApplication(t *testing.T) {
+	application := Application{
+		Name: "collector",
+		Type: RuntimeSystemd,
+		Spec: ApplicationSpec{
+			SystemdSpec: &SystemdSpec{
+				UnitName:  "collector",
+				ExecStart: "/usr/local/bin/collector --site ${{ .Device.Labels.site }}",
+			},
+		},
+	}
+
+	rendered, err := RenderApplication(application, TemplateData{Device: &Device{Labels: map[string]string{"site": "riga"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/local/bin/collector --site riga", rendered.Spec.SystemdSpec.ExecStart)
+}
@@ -0,0 +1,104 @@
+package synpse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// IdentityType is the type of the caller that the access key belongs to.
+type IdentityType string
+
+const (
+	// IdentityTypeUser is a personal access key that can access all projects the user is a member of.
+	IdentityTypeUser IdentityType = "user"
+	// IdentityTypeServiceAccount is a service account access key limited to a single project.
+	IdentityTypeServiceAccount IdentityType = "serviceAccount"
+)
+
+// Identity describes the caller of the API.
+type Identity struct {
+	Type IdentityType `json:"type" yaml:"type"`
+
+	User           *User           `json:"user,omitempty" yaml:"user,omitempty"`
+	ServiceAccount *ServiceAccount `json:"serviceAccount,omitempty" yaml:"serviceAccount,omitempty"`
+
+	// Memberships of the user together with their projects and roles. Empty for service accounts.
+	Memberships []Membership `json:"memberships,omitempty" yaml:"memberships,omitempty"`
+	// Quota of the user. Empty for service accounts.
+	Quota UserQuota `json:"quota" yaml:"quota"`
+}
+
+// ProjectRoles returns roles of the caller in the project. ok is false if the caller has no access to the
+// project.
+func (i *Identity) ProjectRoles(projectID string) (roles []Role, ok bool) {
+	if i.ServiceAccount != nil {
+		if i.ServiceAccount.ProjectID != projectID {
+			return nil, false
+		}
+		return i.ServiceAccount.Roles, true
+	}
+
+	for _, m := range i.Memberships {
+		if m.ProjectID == projectID {
+			return m.Roles, true
+		}
+	}
+	return nil, false
+}
+
+// WhoAmI returns the identity of the caller. For personal access keys it includes the user's quota and
+// memberships, for service accounts the project and roles of the service account.
+func (api *API) WhoAmI(ctx context.Context) (*Identity, error) {
+	resp, _, err := api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, meURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity Identity
+	err = json.Unmarshal(resp, &identity)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalError)
+	}
+
+	switch {
+	case identity.ServiceAccount != nil:
+		identity.Type = IdentityTypeServiceAccount
+	case identity.User != nil:
+		identity.Type = IdentityTypeUser
+		identity.Quota = identity.User.Quota
+
+		resp, _, err = api.makeRequestContext(ctx, http.MethodGet, getURL(api.BaseURL, membershipsURL+"?full"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list memberships: %w", err)
+		}
+		err = json.Unmarshal(resp, &identity.Memberships)
+		if err != nil {
+			return nil, errors.Wrap(err, errUnmarshalError)
+		}
+	default:
+		return nil, fmt.Errorf("unknown identity type")
+	}
+
+	return &identity, nil
+}
+
+// validateCredentials checks the access key and, if a project is configured, that the caller can access it.
+func (api *API) validateCredentials(ctx context.Context) error {
+	identity, err := api.WhoAmI(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to validate credentials: %w", err)
+	}
+
+	if api.ProjectID == "" {
+		return nil
+	}
+	_, ok := identity.ProjectRoles(api.ProjectID)
+	if !ok {
+		return fmt.Errorf("failed to validate credentials: no access to project '%s'", api.ProjectID)
+	}
+	return nil
+}
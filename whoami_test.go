@@ -0,0 +1,40 @@
+package synpse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentityProjectRoles(t *testing.T) {
+	admin := Role{Name: "admin"}
+	viewer := Role{Name: "viewer"}
+
+	user := &Identity{
+		Type: IdentityTypeUser,
+		User: &User{ID: "usr_1"},
+		Memberships: []Membership{
+			{UserID: "usr_1", ProjectID: "prj_a", Roles: []Role{admin}},
+			{UserID: "usr_1", ProjectID: "prj_b", Roles: []Role{viewer}},
+		},
+	}
+
+	roles, ok := user.ProjectRoles("prj_b")
+	assert.True(t, ok)
+	assert.Equal(t, []Role{viewer}, roles)
+
+	_, ok = user.ProjectRoles("prj_c")
+	assert.False(t, ok)
+
+	sa := &Identity{
+		Type:           IdentityTypeServiceAccount,
+		ServiceAccount: &ServiceAccount{ID: "sa_1", ProjectID: "prj_a", Roles: []Role{admin}},
+	}
+
+	roles, ok = sa.ProjectRoles("prj_a")
+	assert.True(t, ok)
+	assert.Equal(t, []Role{admin}, roles)
+
+	_, ok = sa.ProjectRoles("prj_b")
+	assert.False(t, ok)
+}